@@ -0,0 +1,67 @@
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/kcmvp/app"
+	"github.com/kcmvp/dbo/scaffold/meta"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"path/filepath"
+)
+
+const (
+	humanFormat = "human"
+	jsonFormat  = "json"
+)
+
+func check(cmd *cobra.Command, _ []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if !lo.Contains([]string{humanFormat, jsonFormat}, format) {
+		return fmt.Errorf("unsupported format %s, supported formats are %s and %s", format, humanFormat, jsonFormat)
+	}
+	var problems meta.Problems
+	if dbo := meta.Build(); dbo.IsError() && !errors.As(dbo.Error(), &problems) {
+		return dbo.Error()
+	}
+	// file is relative to the project root
+	problems = lo.Map(problems, func(p meta.Problem, _ int) meta.Problem {
+		if rel, err := filepath.Rel(app.RootDir(), p.Position.Filename); err == nil && p.Position.IsValid() {
+			p.Position.Filename = rel
+		}
+		return p
+	})
+	if format == jsonFormat {
+		data, err := json.MarshalIndent(lo.Ternary(problems == nil, meta.Problems{}, problems), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	} else {
+		for _, problem := range problems {
+			fmt.Fprintln(cmd.OutOrStdout(), problem.Error())
+		}
+		if len(problems) == 0 {
+			color.Green("no problems found")
+		}
+	}
+	return lo.If(len(problems) > 0, fmt.Errorf("%d problems found", len(problems))).Else(nil)
+}
+
+// checkCmd report all modeling problems of the entities
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Report all modeling problems of the entities",
+	Long: `Report all modeling problems of the entities with source positions.
+It exits with non-zero status when there is any problem, so it can be used in git pre-commit hook
+`,
+	SilenceUsage: true,
+	RunE:         check,
+}
+
+func init() {
+	checkCmd.Flags().String("format", humanFormat, fmt.Sprintf("output format, supported formats are %s and %s", humanFormat, jsonFormat))
+	rootCmd.AddCommand(checkCmd)
+}
@@ -2,23 +2,53 @@ package action
 
 import (
 	"fmt"
+	"github.com/fatih/color"
 	"github.com/kcmvp/app"
 	"github.com/kcmvp/dbo/scaffold/meta"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"path/filepath"
+	"strings"
 )
 
+// validateConfig report all the problems of base and test configurations, warnings are printed and errors abort the command
 func validateConfig(cmd *cobra.Command, args []string) error {
-	return lo.If(len(meta.Platforms()) < 1, fmt.Errorf("can not find datasource in application.yml")).Else(nil)
+	var diagnostics meta.Diagnostics
+	for _, profile := range []string{"", "test"} {
+		diagnostics = lo.UniqBy(append(diagnostics, meta.LoadConfig(profile).Diagnostics()...), func(d meta.Diagnostic) string {
+			return d.String()
+		})
+	}
+	for _, warning := range diagnostics.Warnings() {
+		color.Yellow(warning.String())
+	}
+	if errs := diagnostics.Errors(); len(errs) > 0 {
+		return errs
+	}
+	return lo.If(len(meta.Platforms()) < 1, fmt.Errorf("can not find datasource in application.yaml")).Else(nil)
 }
 
 func generate(cmd *cobra.Command, args []string) error {
-	if diagram := meta.Build(); diagram.IsOk() {
-		return diagram.MustGet().ER(filepath.Join(app.RootDir(), "target"))
-	} else {
+	formats, _ := cmd.Flags().GetStringSlice("format")
+	render, _ := cmd.Flags().GetString("render")
+	openapi, _ := cmd.Flags().GetBool("openapi")
+	diagram := meta.Build()
+	if diagram.IsError() {
 		return diagram.Error()
 	}
+	target := filepath.Join(app.RootDir(), "target")
+	if err := diagram.MustGet().ER(target, formats...); err != nil {
+		return err
+	}
+	if openapi {
+		if err := diagram.MustGet().JSONSchema(target); err != nil {
+			return err
+		}
+	}
+	if len(render) > 0 {
+		return diagram.MustGet().Render(target, render)
+	}
+	return nil
 }
 
 // genCmd Generate ER diagram, schema for project
@@ -33,5 +63,8 @@ An entity struct must implement github.com/kcmvp/dbo/base/IEntity
 }
 
 func init() {
+	genCmd.Flags().StringSlice("format", []string{"d2"}, fmt.Sprintf("formats of ER diagram, supported formats are %s", strings.Join(meta.ERFormats(), ", ")))
+	genCmd.Flags().String("render", "", fmt.Sprintf("render ER diagram as image, supported formats are %s", strings.Join(meta.RenderFormats(), ", ")))
+	genCmd.Flags().Bool("openapi", false, "generate json schema of entities and OpenAPI components")
 	rootCmd.AddCommand(genCmd)
 }
@@ -0,0 +1,44 @@
+package action
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/kcmvp/app"
+	"github.com/kcmvp/dbo/scaffold/meta"
+	"github.com/spf13/cobra"
+	"path/filepath"
+)
+
+func importDB(cmd *cobra.Command, args []string) error {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", args[0]))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	dir, _ := cmd.Flags().GetString("dir")
+	entities := meta.Import(db, filepath.Join(app.RootDir(), dir))
+	if entities.IsError() {
+		return entities.Error()
+	}
+	for _, e := range entities.MustGet() {
+		color.Green("entity %s is generated from table %s", e.Name, e.Table)
+	}
+	return nil
+}
+
+// importCmd generate entities from an existing database
+var importCmd = &cobra.Command{
+	Use:   "import <sqlite file>",
+	Short: "Generate entities from an existing sqlite database",
+	Long: `Generate entities from all the tables of an existing sqlite database.
+Generated entities implement github.com/kcmvp/dbo/base/IEntity, one file per table
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: importDB,
+}
+
+func init() {
+	importCmd.Flags().String("dir", filepath.Join("internal", "entity"), "directory of the generated entities")
+	rootCmd.AddCommand(importCmd)
+}
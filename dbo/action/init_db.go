@@ -39,12 +39,14 @@ func initDB(cmd *cobra.Command, args []string) error {
 }
 
 var initDbCmd = &cobra.Command{
-	Use:       "db",
-	Short:     "init database configuration for project",
-	Long:      "init database configuration for project",
-	ValidArgs: []string{"mysql", "pg"},
-	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
-	RunE:      initDB,
+	Use:   "db",
+	Short: "init database configuration for project",
+	Long:  "init database configuration for project",
+	ValidArgs: lo.Map(meta.Dialects(), func(d meta.Dialect, _ int) string {
+		return d.Name()
+	}),
+	Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE: initDB,
 }
 
 func init() {
@@ -0,0 +1,154 @@
+package action
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/kcmvp/app"
+	"github.com/kcmvp/dbo/scaffold/meta"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"path/filepath"
+	"time"
+)
+
+const migrationDir = "migration"
+
+func diff(cmd *cobra.Command, args []string) error {
+	dbo := meta.Build()
+	if dbo.IsError() {
+		return dbo.Error()
+	}
+	name := lo.FirstOr(args, "migration")
+	migrations := dbo.MustGet().Diff(filepath.Join(app.RootDir(), migrationDir), name)
+	if migrations.IsError() {
+		return migrations.Error()
+	}
+	for _, m := range migrations.MustGet() {
+		ds := lo.If(len(m.Datasource()) > 0, fmt.Sprintf(" of datasource %s", m.Datasource())).Else("")
+		if m.Empty() {
+			color.Green("no changes%s since last migration", ds)
+			continue
+		}
+		for _, warning := range m.Warnings() {
+			color.Yellow("warning: %s", warning)
+		}
+		color.Green("migration %04d%s is generated", m.Version(), ds)
+	}
+	return nil
+}
+
+// migrator create migrator of the datasource specified by flags, and the configured scripts
+func migrator(cmd *cobra.Command, fn func(m meta.Migrator, ds meta.Datasource) error) error {
+	test, _ := cmd.Flags().GetBool("test")
+	name, _ := cmd.Flags().GetString("ds")
+	ds := meta.LoadDatasource(lo.If(test, "test").Else(""), name)
+	if ds.IsError() {
+		return ds.Error()
+	}
+	db, err := sql.Open(ds.MustGet().Driver, ds.MustGet().URL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m := meta.NewMigrator(db, ds.MustGet(), filepath.Join(app.RootDir(), migrationDir))
+	if m.IsError() {
+		return m.Error()
+	}
+	return fn(m.MustGet(), ds.MustGet())
+}
+
+func up(cmd *cobra.Command, _ []string) error {
+	return migrator(cmd, func(m meta.Migrator, ds meta.Datasource) error {
+		applied := m.Up()
+		if applied.IsError() {
+			return applied.Error()
+		}
+		for _, s := range applied.MustGet() {
+			color.Green("migration %04d_%s is applied", s.Version, s.Name)
+		}
+		return m.Scripts(lo.Map(ds.Scripts, func(script string, _ int) string {
+			return filepath.Join(app.RootDir(), script)
+		})...)
+	})
+}
+
+func down(cmd *cobra.Command, _ []string) error {
+	return migrator(cmd, func(m meta.Migrator, _ meta.Datasource) error {
+		steps, _ := cmd.Flags().GetInt("steps")
+		reverted := m.Down(steps)
+		if reverted.IsError() {
+			return reverted.Error()
+		}
+		for _, s := range reverted.MustGet() {
+			color.Green("migration %04d_%s is reverted", s.Version, s.Name)
+		}
+		return nil
+	})
+}
+
+func status(cmd *cobra.Command, _ []string) error {
+	return migrator(cmd, func(m meta.Migrator, _ meta.Datasource) error {
+		status := m.Status()
+		if status.IsError() {
+			return status.Error()
+		}
+		for _, s := range status.MustGet() {
+			if s.AppliedAt.IsPresent() {
+				color.Green("%04d_%s applied at %s", s.Version, s.Name, s.AppliedAt.MustGet().Format(time.DateTime))
+			} else {
+				color.Yellow("%04d_%s pending", s.Version, s.Name)
+			}
+		}
+		return nil
+	})
+}
+
+// migrateCmd database migration of the project
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Database migration of the project",
+	Long:  "Database migration of the project",
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [name]",
+	Short: "Generate migration scripts since last migration",
+	Long: `Generate migration scripts by comparing entities with the snapshot of last migration.
+Scripts of the platforms of every datasource are generated in the migration directory, and destructive changes are reported as warnings
+`,
+	Args:              cobra.MaximumNArgs(1),
+	PersistentPreRunE: validateConfig,
+	RunE:              diff,
+}
+
+var upCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply pending migrations to the datasource",
+	Long: `Apply pending migrations to the datasource in version order, then execute the scripts of the datasource.
+Applied versions are recorded in table dbo_migration
+`,
+	RunE: up,
+}
+
+var downCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Revert applied migrations of the datasource",
+	Long:  "Revert applied migrations of the datasource in reverse version order",
+	RunE:  down,
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show migration status of the datasource",
+	Long:  "Show migration status of the datasource",
+	RunE:  status,
+}
+
+func init() {
+	migrateCmd.PersistentFlags().String("ds", "", "datasource name for multiple datasource project")
+	migrateCmd.PersistentFlags().Bool("test", false, "use test profile configuration")
+	downCmd.Flags().Int("steps", 1, "number of migrations to revert")
+	migrateCmd.AddCommand(diffCmd, upCmd, downCmd, statusCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
@@ -0,0 +1,397 @@
+package meta
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kcmvp/app"
+	"github.com/samber/lo"
+	"github.com/samber/mo"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Severity severity of a diagnostic
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+const envFile = "env"
+
+var yamlLineReg = regexp.MustCompile(`line (\d+)`)
+
+// Diagnostic problem of the configuration with the file and line where it's found, the line is 0
+// when the problem is not on a specific line
+type Diagnostic struct {
+	Severity Severity
+	File     string
+	Line     int
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	location := lo.If(d.Line > 0, fmt.Sprintf("%s:%d", d.File, d.Line)).Else(d.File)
+	return fmt.Sprintf("%s: %s: %s", lo.If(d.Severity == SeverityError, "error").Else("warning"), location, d.Message)
+}
+
+// Diagnostics all the problems of the configuration, it's an error when it's not empty
+type Diagnostics []Diagnostic
+
+// Errors return the diagnostics with error severity
+func (ds Diagnostics) Errors() Diagnostics {
+	return lo.Filter(ds, func(d Diagnostic, _ int) bool {
+		return d.Severity == SeverityError
+	})
+}
+
+// Warnings return the diagnostics with warning severity
+func (ds Diagnostics) Warnings() Diagnostics {
+	return lo.Filter(ds, func(d Diagnostic, _ int) bool {
+		return d.Severity == SeverityWarning
+	})
+}
+
+func (ds Diagnostics) Error() string {
+	return strings.Join(lo.Map(ds, func(d Diagnostic, _ int) string {
+		return d.String()
+	}), "\n")
+}
+
+// setting value of a configuration key and where it's set, sequence value is in values
+type setting struct {
+	value  string
+	values []string
+	file   string
+	line   int
+}
+
+// Config configuration of the project layered by profile. settings of application.yaml are overridden by
+// application_<profile>.yaml, then by environment variables, e.g. DATASOURCE_DS1_URL for datasource.ds1.url.
+// environment variables also define missing properties of the declared datasources. keys are case-insensitive
+type Config struct {
+	settings    map[string]setting
+	datasources []Datasource
+	types       mo.Result[typeMappings]
+	naming      mo.Result[mo.Option[Naming]]
+	quote       bool
+	diagnostics Diagnostics
+}
+
+// LoadConfig load configuration of the profile, only application.yaml is loaded when profile is empty.
+// problems are collected as diagnostics instead of aborting on the first one
+func LoadConfig(profile string) Config {
+	return loadConfig(app.RootDir(), profile)
+}
+
+// loadConfig load configuration of the profile in the directory
+func loadConfig(dir, profile string) Config {
+	cfg := Config{settings: map[string]setting{}}
+	files := []string{fmt.Sprintf("%s.yaml", app.DefaultCfgName)}
+	if len(profile) > 0 {
+		files = append(files, fmt.Sprintf("%s_%s.yaml", app.DefaultCfgName, profile))
+	}
+	for i, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if errors.Is(err, os.ErrNotExist) {
+			// the profile configuration is optional
+			cfg.report(lo.If(i == 0, SeverityError).Else(SeverityWarning), file, 0, "configuration file is missing")
+			continue
+		} else if err != nil {
+			cfg.report(SeverityError, file, 0, "%s", err.Error())
+			continue
+		}
+		var root yaml.Node
+		if err = yaml.Unmarshal(data, &root); err != nil {
+			line := 0
+			if matches := yamlLineReg.FindStringSubmatch(err.Error()); len(matches) > 1 {
+				line, _ = strconv.Atoi(matches[1])
+			}
+			cfg.report(SeverityError, file, line, "%s", err.Error())
+			continue
+		}
+		if len(root.Content) > 0 {
+			cfg.load(file, "", root.Content[0])
+		}
+	}
+	// environment variables override the settings in the files, sequence value is comma separated
+	for key := range cfg.settings {
+		if v, ok := os.LookupEnv(strings.ToUpper(strings.ReplaceAll(key, ".", "_"))); ok && !cfg.mapping(key) {
+			cfg.settings[key] = setting{value: v, values: strings.Split(v, ","), file: envFile}
+		}
+	}
+	cfg.define()
+	cfg.datasources = cfg.loadDatasources()
+	cfg.types = cfg.loadTypes()
+	cfg.naming = cfg.loadNaming()
+	cfg.quote = cfg.loadQuote()
+	slices.SortStableFunc(cfg.diagnostics, func(a, b Diagnostic) int {
+		return lo.Ternary(a.File == b.File, a.Line-b.Line, strings.Compare(a.File, b.File))
+	})
+	return cfg
+}
+
+// report add a diagnostic
+func (cfg *Config) report(severity Severity, file string, line int, format string, args ...any) {
+	cfg.diagnostics = append(cfg.diagnostics, Diagnostic{Severity: severity, File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// load flatten the node into settings with dot separated keys
+func (cfg *Config) load(file, prefix string, node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		seen := map[string]int{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			name := strings.ToLower(k.Value)
+			if line, ok := seen[name]; ok {
+				what := lo.If(prefix == "datasource", "datasource name").Else("key")
+				cfg.report(SeverityError, file, k.Line, "duplicate %s %s, it's defined at line %d", what, k.Value, line)
+				continue
+			}
+			seen[name] = k.Line
+			key := strings.TrimPrefix(fmt.Sprintf("%s.%s", prefix, name), ".")
+			cfg.settings[key] = setting{file: file, line: k.Line}
+			cfg.load(file, key, v)
+		}
+	case yaml.SequenceNode:
+		// sequence of the profile replaces the one of the base as a whole, items are keyed by index
+		for key := range cfg.settings {
+			if strings.HasPrefix(key, prefix+".") {
+				delete(cfg.settings, key)
+			}
+		}
+		s := cfg.settings[prefix]
+		s.values = lo.Map(node.Content, func(item *yaml.Node, _ int) string {
+			return item.Value
+		})
+		cfg.settings[prefix] = s
+		for i, item := range node.Content {
+			if item.Kind == yaml.MappingNode {
+				key := fmt.Sprintf("%s.%d", prefix, i)
+				cfg.settings[key] = setting{file: file, line: item.Line}
+				cfg.load(file, key, item)
+			}
+		}
+	case yaml.ScalarNode:
+		s := cfg.settings[prefix]
+		s.value, s.values = node.Value, []string{node.Value}
+		cfg.settings[prefix] = s
+	}
+}
+
+// define add properties of the declared datasources which are only set by environment variables,
+// e.g. DATASOURCE_DS1_PASSWORD for datasource.ds1.password
+func (cfg *Config) define() {
+	names := cfg.datasourceNames()
+	// named datasources take precedence over the single datasource, DATASOURCE_DS1_PASSWORD is not ds1_password
+	slices.SortFunc(names, func(a, b string) int {
+		return len(b) - len(a)
+	})
+	for _, env := range os.Environ() {
+		k, v, _ := strings.Cut(env, "=")
+		for _, name := range names {
+			prefix := datasourcePrefix(name)
+			if p, ok := strings.CutPrefix(k, strings.ToUpper(strings.ReplaceAll(prefix, ".", "_"))+"_"); ok && len(p) > 0 {
+				if key := fmt.Sprintf("%s.%s", prefix, strings.ToLower(p)); cfg.get(key).IsAbsent() {
+					cfg.settings[key] = setting{value: v, values: strings.Split(v, ","), file: envFile}
+				}
+				break
+			}
+		}
+	}
+}
+
+// get return the setting of the key
+func (cfg Config) get(key string) mo.Option[setting] {
+	s, ok := cfg.settings[key]
+	return lo.If(ok, mo.Some(s)).Else(mo.None[setting]())
+}
+
+// loadDatasources build and validate datasources. properties directly under `datasource` are the single
+// datasource without name, mappings under `datasource` are named datasources
+func (cfg *Config) loadDatasources() []Datasource {
+	var datasources []Datasource
+	for _, name := range cfg.datasourceNames() {
+		prefix := datasourcePrefix(name)
+		property := func(p string) setting {
+			return cfg.get(fmt.Sprintf("%s.%s", prefix, p)).OrEmpty()
+		}
+		ds := Datasource{Name: name, DB: property("db").value, Driver: property("driver").value, Scripts: property("scripts").values}
+		where := cfg.get(prefix).OrElse(property("driver"))
+		valid := true
+		if len(ds.Driver) == 0 {
+			cfg.report(SeverityError, where.file, where.line, "driver of datasource %s is missing", lo.If(len(name) > 0, name).Else("datasource"))
+			valid = false
+		} else if db, ok := lo.Find(SupportedDB(), func(db DBType) bool {
+			return db.Driver == ds.Driver
+		}); !ok {
+			s := property("driver")
+			cfg.report(SeverityError, s.file, s.line, "unknown driver %s", ds.Driver)
+			valid = false
+		} else if len(ds.DB) == 0 {
+			ds.DB = db.DB
+		}
+		if len(ds.DB) > 0 && DialectOf(ds.DB).IsAbsent() {
+			s := property("db")
+			cfg.report(SeverityError, s.file, s.line, "unsupported db %s", ds.DB)
+			valid = false
+		}
+		// unresolved placeholders are kept and reported as warning, the url is only required by migration
+		url := property("url")
+		ds.URL = placeholderReg.ReplaceAllStringFunc(url.value, func(placeholder string) string {
+			p := placeholderReg.FindStringSubmatch(placeholder)[1]
+			if s := cfg.get(fmt.Sprintf("%s.%s", prefix, strings.ToLower(p))); s.IsPresent() && len(s.MustGet().value) > 0 {
+				return s.MustGet().value
+			}
+			return placeholder
+		})
+		if unresolved := placeholderReg.FindAllString(ds.URL, -1); len(unresolved) > 0 {
+			cfg.report(SeverityWarning, url.file, url.line, "unresolved %s in url", strings.Join(lo.Uniq(unresolved), ", "))
+		}
+		if valid {
+			datasources = append(datasources, ds)
+		}
+	}
+	return datasources
+}
+
+// datasourceNames names of the declared datasources in order, it's empty for the single datasource
+func (cfg Config) datasourceNames() []string {
+	var names []string
+	for key := range cfg.settings {
+		parts := strings.Split(key, ".")
+		if parts[0] != "datasource" || len(parts) < 2 {
+			continue
+		}
+		if len(parts) == 2 && !cfg.mapping(key) {
+			names = append(names, "")
+		} else {
+			names = append(names, parts[1])
+		}
+	}
+	names = lo.Uniq(names)
+	slices.Sort(names)
+	return names
+}
+
+// datasourcePrefix key prefix of the datasource properties
+func datasourcePrefix(name string) string {
+	return strings.TrimSuffix(fmt.Sprintf("datasource.%s", name), ".")
+}
+
+// loadTypes build custom type mappings of `dbo.types`, each item is a mapping of the go type and
+// sql types of the dialects
+func (cfg *Config) loadTypes() mo.Result[typeMappings] {
+	types := typeMappings{}
+	s := cfg.get("dbo.types")
+	if s.IsAbsent() {
+		return mo.Ok(types)
+	}
+	var diagnostics Diagnostics
+	invalid := func(file string, line int, format string, args ...any) {
+		cfg.report(SeverityError, file, line, format, args...)
+		diagnostics = append(diagnostics, cfg.diagnostics[len(cfg.diagnostics)-1])
+	}
+	if len(s.MustGet().value) > 0 || (len(s.MustGet().values) == 0 && cfg.mapping("dbo.types")) {
+		invalid(s.MustGet().file, s.MustGet().line, "dbo.types should be a sequence of type mappings")
+		return mo.Err[typeMappings](diagnostics)
+	}
+	for i := range s.MustGet().values {
+		key := fmt.Sprintf("dbo.types.%d", i)
+		item, ok := cfg.get(key).Get()
+		if !ok {
+			invalid(s.MustGet().file, s.MustGet().line, "type mapping %d of dbo.types should be a mapping", i+1)
+			continue
+		}
+		goType := cfg.get(key + ".go").OrEmpty().value
+		if len(goType) == 0 {
+			invalid(item.file, item.line, "go type of the type mapping is missing")
+			continue
+		}
+		types[GoType(goType)] = map[string]string{}
+		for k, v := range cfg.settings {
+			if dialect, ok := strings.CutPrefix(k, key+"."); ok && dialect != "go" && !strings.Contains(dialect, ".") {
+				types[GoType(goType)][dialect] = v.value
+			}
+		}
+	}
+	return lo.If(len(diagnostics) > 0, mo.Err[typeMappings](diagnostics)).Else(mo.Ok(types))
+}
+
+// loadNaming build naming strategy of `dbo.naming`, it's absent when it's not configured
+func (cfg *Config) loadNaming() mo.Result[mo.Option[Naming]] {
+	s, ok := cfg.get("dbo.naming").Get()
+	if !ok {
+		return mo.Ok(mo.None[Naming]())
+	}
+	if !cfg.mapping("dbo.naming") {
+		cfg.report(SeverityError, s.file, s.line, "dbo.naming should be a mapping of strategy and prefix")
+		return mo.Err[mo.Option[Naming]](Diagnostics{cfg.diagnostics[len(cfg.diagnostics)-1]})
+	}
+	strategy := cfg.get("dbo.naming.strategy").OrElse(s)
+	naming := Naming{Strategy: lo.CoalesceOrEmpty(strategy.value, "snake"), Prefix: cfg.get("dbo.naming.prefix").OrEmpty().value}
+	if _, ok = namingStrategies[naming.Strategy]; !ok {
+		strategies := lo.Keys(namingStrategies)
+		slices.Sort(strategies)
+		cfg.report(SeverityError, strategy.file, strategy.line, "unknown naming strategy %s, it should be one of %s", naming.Strategy, strings.Join(strategies, ", "))
+		return mo.Err[mo.Option[Naming]](Diagnostics{cfg.diagnostics[len(cfg.diagnostics)-1]})
+	}
+	return mo.Ok(mo.Some(naming))
+}
+
+// loadQuote identify `dbo.quote` is enabled, it's disabled when it's not configured
+func (cfg *Config) loadQuote() bool {
+	s, ok := cfg.get("dbo.quote").Get()
+	if !ok {
+		return false
+	}
+	quote, err := strconv.ParseBool(s.value)
+	if err != nil {
+		cfg.report(SeverityError, s.file, s.line, "dbo.quote should be true or false")
+	}
+	return quote
+}
+
+// mapping identify the key has nested keys
+func (cfg Config) mapping(key string) bool {
+	return lo.SomeBy(lo.Keys(cfg.settings), func(k string) bool {
+		return strings.HasPrefix(k, key+".")
+	})
+}
+
+// Diagnostics problems found when loading the configuration in file and line order
+func (cfg Config) Diagnostics() Diagnostics {
+	return cfg.diagnostics
+}
+
+// Datasources valid datasources of the configuration in name order
+func (cfg Config) Datasources() []Datasource {
+	return cfg.datasources
+}
+
+// Datasource return the datasource by name, name is empty for single datasource project. it's an error when
+// there are unresolved placeholders in the url, as the datasource is used to connect the database
+func (cfg Config) Datasource(name string) mo.Result[Datasource] {
+	if len(cfg.Diagnostics().Errors()) > 0 {
+		return mo.Err[Datasource](cfg.Diagnostics())
+	}
+	ds, ok := lo.Find(cfg.datasources, func(ds Datasource) bool {
+		return ds.Name == strings.ToLower(name)
+	})
+	if !ok {
+		return mo.Err[Datasource](fmt.Errorf("can not find datasource %s", name))
+	}
+	if unresolved := placeholderReg.FindAllString(ds.URL, -1); len(unresolved) > 0 {
+		s := cfg.get(datasourcePrefix(ds.Name) + ".url").OrEmpty()
+		return mo.Err[Datasource](Diagnostics{{Severity: SeverityError, File: s.file, Line: s.line,
+			Message: fmt.Sprintf("unresolved %s in url", strings.Join(lo.Uniq(unresolved), ", "))}})
+	}
+	return mo.Ok(ds)
+}
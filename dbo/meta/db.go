@@ -3,12 +3,11 @@ package meta
 import (
 	_ "embed"
 	"encoding/json"
-	"fmt"
-	"github.com/kcmvp/app"
 	"github.com/samber/lo"
 	"github.com/samber/mo"
-	"github.com/spf13/viper"
 	"regexp"
+	"slices"
+	"strings"
 )
 
 type GoType string
@@ -21,7 +20,6 @@ var (
 type DBType struct {
 	DB      string `json:"db"`
 	Driver  string `json:"driver"`
-	Auto    string `json:"auto"`
 	Module  string `json:"module"`
 	Url     string `json:"url"`
 	Default bool   `json:"default"`
@@ -33,26 +31,27 @@ func SupportedDB() []DBType {
 	return supported
 }
 
-func Platforms() []string {
-	var dbs []string
-	config := []string{app.DefaultCfgName, fmt.Sprintf("%s_test", app.DefaultCfgName)}
-	reg := regexp.MustCompile(`datasource.*\.db`)
-	for _, cfgName := range config {
-		cfg := viper.New()
-		cfg.SetConfigName(cfgName)
-		cfg.SetConfigType("yaml")
-		cfg.AddConfigPath(app.RootDir())
-		if err := cfg.ReadInConfig(); err != nil {
-			panic(fmt.Errorf("fatal error cfg file: %w", err))
+// Datasources return platforms of the datasources in the base and test configurations, the key is the
+// datasource name, and it's empty for single datasource project. problems of the configurations are ignored,
+// they are reported by LoadConfig
+func Datasources() map[string][]string {
+	datasources := map[string][]string{}
+	for _, profile := range []string{"", "test"} {
+		for _, ds := range LoadConfig(profile).Datasources() {
+			datasources[ds.Name] = lo.Uniq(append(datasources[ds.Name], ds.DB))
 		}
-		dbs = append(dbs, lo.FilterMap(cfg.AllKeys(), func(key string, _ int) (string, bool) {
-			if reg.MatchString(key) {
-				return cfg.GetString(key), true
-			}
-			return "", false
-		})...)
 	}
-	return lo.Uniq(dbs)
+	return datasources
+}
+
+// Platforms return platforms of all the datasources
+func Platforms() []string {
+	datasources := Datasources()
+	names := lo.Keys(datasources)
+	slices.Sort(names)
+	return lo.Uniq(lo.FlatMap(names, func(name string, _ int) []string {
+		return datasources[name]
+	}))
 }
 
 func DB(db string) mo.Option[DBType] {
@@ -61,110 +60,137 @@ func DB(db string) mo.Option[DBType] {
 	}))
 }
 
-type TypeMapping lo.Tuple4[GoType, string, string, string]
-
-func TypeMappings() []TypeMapping {
-	return []TypeMapping{
-		{
-			A: "string",
-			B: "varchar(25)", //MySQL
-			C: "varchar(25)", //PostgreSQL
-			D: "text(25)",    //SQLite
-		},
-		{
-			A: "bool",
-			B: "boolean",
-			C: "boolean",
-			D: "integer",
-		},
-		{
-			A: "int8",
-			B: "tinyint",
-			C: "int8",
-			D: "integer",
-		},
-		// unsigned, not a SQL standard
-		{
-			A: "uint8",
-			B: "tinyint unsigned",
-			C: "int8",
-			D: "integer",
-		},
-		// unsigned, not a SQL standard
-		{
-			A: "byte",
-			B: "tinyint unsigned",
-			C: "int8",
-			D: "integer",
-		},
-		{
-			A: "int16",
-			B: "smallint",
-			C: "smallint",
-			D: "integer",
-		},
-		// unsigned, not a SQL standard
-		{
-			A: "uint16",
-			B: "smallint",
-			C: "smallint",
-			D: "integer",
-		},
-		{
-			A: "int32",
-			B: "int",
-			C: "integer",
-			D: "integer",
-		},
-		{
-			A: "rune",
-			B: "int",
-			C: "integer",
-			D: "integer",
-		},
-		// unsigned, not a SQL standard
-		{
-			A: "uint32",
-			B: "int",
-			C: "integer",
-			D: "integer",
-		},
-		{
-			A: "int64",
-			B: "bigint",
-			C: "bigint",
-			D: "integer",
-		},
-		{
-			A: "int",
-			B: "bigint",
-			C: "bigint",
-			D: "integer",
-		},
-		// unsigned, not a SQL standard
-		{
-			A: "uint64",
-			B: "bigint",
-			C: "bigint",
-			D: "integer",
-		},
-		{
-			A: "float32",
-			B: "decimal(38, 2)",
-			C: "decimal(38, 2)",
-			D: "decimal(38, 2)",
-		},
-		{
-			A: "float64",
-			B: "decimal(38, 2)",
-			C: "decimal(38, 2)",
-			D: "decimal(38, 2)",
-		},
-		{
-			A: "time.Time",
-			B: "timestamp",
-			C: "timestamp",
-			D: "datetime",
-		},
+// TypeMapping sql types of the go type, the key of the map is the dialect name
+type TypeMapping lo.Tuple2[GoType, map[string]string]
+
+// typeMappings custom type mappings configured in the project, the keys are go type and the dialect names.
+// they are configured as below
+//
+//	dbo:
+//	  types:
+//	    - go: github.com/google/uuid.UUID
+//	      mysql: binary(16)
+//	      pg: uuid
+//	      sqlite: blob
+type typeMappings map[GoType]map[string]string
+
+// customTypes load custom type mappings from the configuration, it's an error when the configuration is malformed.
+// they are loaded once by Build and passed down to the columns
+func customTypes() mo.Result[typeMappings] {
+	return modelConfig().types
+}
+
+// Naming naming strategy of implicit table and column names, it's configured as below. Strategy is
+// one of snake and lowerCamel, and prefix is the prefix of table names
+//
+//	dbo:
+//	  naming:
+//	    strategy: snake
+//	    prefix: t_
+type Naming struct {
+	Strategy string
+	Prefix   string
+}
+
+var namingStrategies = map[string]func(string) string{
+	"snake":      lo.SnakeCase,
+	"lowerCamel": lo.CamelCase,
+}
+
+// Column column name of the attribute
+func (n Naming) Column(attr string) string {
+	return namingStrategies[n.Strategy](attr)
+}
+
+// Table table name of the entity
+func (n Naming) Table(entity string) string {
+	return n.Prefix + namingStrategies[n.Strategy](entity)
+}
+
+// loadNaming load naming strategy from the configuration, it's absent when it's not configured
+func loadNaming() mo.Result[mo.Option[Naming]] {
+	return modelConfig().naming
+}
+
+// modelConfig configuration of the modeling settings, the test profile is layered on the base one, as the
+// artifacts are generated for datasources of both profiles
+func modelConfig() Config {
+	return LoadConfig("test")
+}
+
+// quoting identify reserved words are quoted in the generated scripts, they are rejected when it's disabled.
+// it's configured as below
+//
+//	dbo:
+//	  quote: true
+func quoting() bool {
+	return modelConfig().quote
+}
+
+// all return type mappings of all the dialects, custom type mappings take precedence over the builtin
+// type mappings of dialects
+func (m typeMappings) all() []TypeMapping {
+	mappings := map[GoType]map[string]string{}
+	for _, d := range Dialects() {
+		for goType, sqlType := range d.Types() {
+			if _, ok := mappings[goType]; !ok {
+				mappings[goType] = map[string]string{}
+			}
+			mappings[goType][d.Name()] = sqlType
+		}
 	}
+	for goType, types := range m {
+		mappings[goType] = lo.Assign(mappings[goType], types)
+	}
+	return lo.MapToSlice(mappings, func(goType GoType, types map[string]string) TypeMapping {
+		return TypeMapping{A: goType, B: types}
+	})
+}
+
+// sqlType sql type of the go type in the dialect, custom type mappings take precedence
+func (m typeMappings) sqlType(d Dialect, goType GoType) mo.Option[string] {
+	if typ, ok := m[goType][d.Name()]; ok {
+		return mo.Some(typ)
+	}
+	typ, ok := d.Types()[goType]
+	return lo.If(ok, mo.Some(typ)).Else(mo.None[string]())
+}
+
+// mapped identify the go type is mapped in any dialect
+func (m typeMappings) mapped(goType GoType) bool {
+	return lo.ContainsBy(m.all(), func(mapping TypeMapping) bool {
+		return mapping.A == goType
+	})
+}
+
+// precisions max number of precision values taken by the sql types of the go type in all the dialects,
+// e.g. 1 for varchar(25) and 2 for decimal(38, 2). it's 0 when the go type does not take precision
+func (m typeMappings) precisions(goType GoType) int {
+	return lo.Max(lo.FlatMap(m.all(), func(mapping TypeMapping, _ int) []int {
+		if mapping.A != goType {
+			return nil
+		}
+		return lo.MapToSlice(mapping.B, func(_ string, typ string) int {
+			matches := preReg.FindStringSubmatch(typ)
+			return lo.If(len(matches) > 1, len(strings.Split(lo.LastOrEmpty(matches), ","))).Else(0)
+		})
+	}))
+}
+
+var placeholderReg = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// Datasource connection settings of a datasource in the configuration
+type Datasource struct {
+	Name    string
+	DB      string
+	Driver  string
+	URL     string
+	Scripts []string
+}
+
+// LoadDatasource load datasource of the profile. name is the datasource name for multiple datasource project,
+// and it's empty for single datasource project. placeholders in the url are resolved by the properties of
+// the datasource
+func LoadDatasource(profile, name string) mo.Result[Datasource] {
+	return LoadConfig(profile).Datasource(name)
 }
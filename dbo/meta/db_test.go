@@ -8,3 +8,12 @@ import (
 func TestPlatforms(t *testing.T) {
 	assert.Len(t, Platforms(), 3)
 }
+
+func TestSupportedDB(t *testing.T) {
+	// scripts and migrations may have several statements, mysql driver rejects them by default
+	for _, db := range SupportedDB() {
+		if db.Driver == "mysql" {
+			assert.Contains(t, db.Url, "multiStatements=true", db.DB)
+		}
+	}
+}
@@ -1,18 +1,19 @@
 package meta
 
 import (
+	"bytes"
 	_ "embed"
+	"errors"
 	"fmt"
 	"github.com/dominikbraun/graph"
 	"github.com/kcmvp/app"
 	"github.com/samber/lo"
 	"github.com/samber/mo"
 	"go/ast"
-	"go/format"
+	"go/constant"
 	"go/token"
 	"go/types"
 	"golang.org/x/tools/go/packages"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -20,18 +21,33 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 )
 
 var (
 	//go:embed er.tmpl
 	erTmpl string
+	//go:embed er_mermaid.tmpl
+	erMermaidTmpl string
+	//go:embed er_plantuml.tmpl
+	erPlantUMLTmpl string
+	//go:embed er_dot.tmpl
+	erDotTmpl string
 	//go:embed schema.tmpl
 	schemaTmpl string
 	//go:embed column.tmpl
 	columnTmpl string
 
-	dbReg  = regexp.MustCompile(`db:\s*"([^"]*)"`)
-	preReg = regexp.MustCompile(`\(([^)]+)\)`)
+	dbReg    = regexp.MustCompile(`db:\s*"([^"]*)"`)
+	preReg   = regexp.MustCompile(`\(([^)]+)\)`)
+	identReg = regexp.MustCompile(`[^\w\[\]]`)
+
+	refActions = []string{"cascade", "restrict", "set null", "set default", "no action"}
+
+	intTypes   = []GoType{"int", "int8", "int16", "int32", "int64", "rune", "time.Duration"}
+	uintTypes  = []GoType{"uint", "uint8", "uint16", "uint32", "uint64", "byte"}
+	floatTypes = []GoType{"float32", "float64", "github.com/shopspring/decimal.Decimal"}
+	timeLayout = []string{time.DateTime, time.DateOnly, time.RFC3339}
 )
 
 type ColProperty string
@@ -42,26 +58,88 @@ const (
 	colRef        ColProperty = "ref"
 	colPK         ColProperty = "pk"
 	colSeq        ColProperty = "seq"
+	colIdx        ColProperty = "idx"
+	colUniq       ColProperty = "uniq"
+	colOnDelete   ColProperty = "onDelete"
+	colOnUpdate   ColProperty = "onUpdate"
+	colDefault    ColProperty = "default"
+	colCheck      ColProperty = "check"
+	colAct        ColProperty = "act"
+	colAut        ColProperty = "aut"
+	colM2M        ColProperty = "m2m"
 	sqlTypePrefix             = "database/sql.Null"
+	// datasourceDirective directive on the entity or its Table method to declare the datasource of the entity
+	datasourceDirective = "//dbo:datasource "
 )
 
-type Column lo.Tuple3[string, string, string]
+// Column attribute(A), go type(B) and db tag(C) of the entity field
+type Column struct {
+	A    string
+	B    string
+	C    string
+	enum mo.Option[Enum]
+	doc  string
+	// pos declaration of the field, it's used to report problems
+	pos token.Pos
+	// types custom type mappings of the project, builtin type mappings of the dialects are used when it's nil
+	types typeMappings
+}
+
+// Enum named basic type and its constants, the constants are the allowed values of the column
+type Enum struct {
+	name   string
+	typ    GoType
+	values []string
+}
+
+// Name sql type name of the enum for the platforms supporting native enum
+func (e Enum) Name() string {
+	return lo.SnakeCase(e.name)
+}
+
+// Type underlying go type of the enum
+func (e Enum) Type() GoType {
+	return e.typ
+}
+
+// Values values of the enum in declaration order
+func (e Enum) Values() []string {
+	return e.values
+}
+
+// Literals sql literals of the values
+func (e Enum) Literals() string {
+	return strings.Join(lo.Map(e.values, func(v string, _ int) string {
+		return lo.If(e.typ == "string", fmt.Sprintf("'%s'", strings.ReplaceAll(v, "'", "''"))).Else(v)
+	}), ", ")
+}
+
+// Label enum name with values for ER diagram
+func (e Enum) Label() string {
+	return fmt.Sprintf("%s [%s]", e.name, strings.Join(e.values, ", "))
+}
+
+// native identify the enum is created as a type in the dialect, only string enums are supported
+func (e Enum) native(d Dialect) bool {
+	return d.NativeEnum() && e.typ == "string"
+}
 
-// Property get column properties
+func (e Enum) equal(other Enum) bool {
+	return e.name == other.name && e.typ == other.typ && slices.Equal(e.values, other.values)
+}
+
+// Property get column properties, the value is the part after the first '=' so that it can be an expression
 func (c Column) Property(property ColProperty) mo.Option[string] {
-	pairs := strings.Split(c.C, ";")
-	for _, pair := range pairs {
-		kv := strings.FieldsFunc(pair, func(r rune) bool {
-			return r == '='
-		})
-		if strings.TrimSpace(kv[0]) == string(property) {
-			return lo.IfF(len(kv) == 2, func() mo.Option[string] {
-				v := strings.TrimSpace(kv[1])
-				if len(v) == 0 {
-					return mo.None[string]()
-				}
-				return mo.Some(v)
-			}).Else(mo.Some[string](string(property)))
+	for _, pair := range strings.Split(c.C, ";") {
+		k, v, ok := strings.Cut(pair, "=")
+		if strings.TrimSpace(k) == string(property) {
+			if !ok {
+				return mo.Some(string(property))
+			}
+			if v = strings.TrimSpace(v); len(v) == 0 {
+				return mo.None[string]()
+			}
+			return mo.Some(v)
 		}
 	}
 	return mo.None[string]()
@@ -96,21 +174,36 @@ func (c Column) Nullable() bool {
 	return strings.HasPrefix(c.AttrType(), sqlTypePrefix) || strings.HasPrefix(c.AttrType(), "*")
 }
 
-// Def generate column definition
-func (c Column) Def(db string) string {
-	cTyp := strings.ReplaceAll(c.AttrType(), sqlTypePrefix, "")
-	if cTyp != c.AttrType() {
-		cTyp = strings.ToLower(cTyp)
+// Doc doc comment of the entity field
+func (c Column) Doc() string {
+	return c.doc
+}
+
+// Enum return the enum of the column when the go type is a named basic type with constants
+func (c Column) Enum() mo.Option[Enum] {
+	return c.enum.FlatMap(func(e Enum) mo.Option[Enum] {
+		return lo.If(len(e.values) > 0, mo.Some(e)).Else(mo.None[Enum]())
+	})
+}
+
+// GoType go type of the column for type mapping, pointer and sql.Null types are resolved to the value type,
+// and named basic types are resolved to the underlying type
+func (c Column) GoType() GoType {
+	if c.enum.IsPresent() {
+		return c.enum.MustGet().typ
 	}
-	cTyp = strings.ReplaceAll(cTyp, "*", "")
-	op := mo.TupleToOption(lo.Find(TypeMappings(), func(m TypeMapping) bool {
-		return string(m.A) == cTyp
-	}))
-	if op.IsAbsent() {
-		panic(fmt.Sprintf("can not find type mapping for %s", c.AttrType()))
+	return valueType(c.AttrType())
+}
+
+// Type sql type of the column with precision, type mapping of the column is validated before generation
+func (c Column) Type(db string) string {
+	var typ string
+	if d := DialectOf(db); d.IsPresent() {
+		if e := c.Enum(); e.IsPresent() && e.MustGet().native(d.MustGet()) {
+			return d.MustGet().Ident(e.MustGet().Name())
+		}
+		typ = c.types.sqlType(d.MustGet(), c.GoType()).OrEmpty()
 	}
-	typ := op.MustGet()
-	def := fmt.Sprintf("%s %s", lo.If(db == "mysql", typ.B).ElseIf(db == "pg", typ.C).Else(typ.D), lo.If(!c.Nullable(), "not null").Else(""))
 	// precision
 	matches := preReg.FindStringSubmatch(c.Property(colName).MustGet())
 	if len(matches) > 1 {
@@ -119,20 +212,208 @@ func (c Column) Def(db string) string {
 		re := regexp.MustCompile(`\s+`)
 		precision = re.ReplaceAllString(precision, " ")
 		// Replace the value in the second string
-		def = preReg.ReplaceAllStringFunc(def, func(match string) string {
+		typ = preReg.ReplaceAllStringFunc(typ, func(match string) string {
 			return fmt.Sprintf("(%s)", precision)
 		})
 	}
-	if c.Property(colPK).IsPresent() && c.AttrType() == "int64" {
-		def = fmt.Sprintf("%s %s", def, DB(db).MustGet().Auto)
+	return typ
+}
+
+// precision return the precision of the column, e.g. [20] for `col=name(20)` and [10 2] for `col=price(10,2)`
+func (c Column) precision() []int {
+	matches := preReg.FindStringSubmatch(c.Property(colName).MustGet())
+	if len(matches) < 2 {
+		return []int{}
+	}
+	return lo.FilterMap(strings.Split(matches[1], ","), func(item string, _ int) (int, bool) {
+		v, err := strconv.Atoi(strings.TrimSpace(item))
+		return v, err == nil
+	})
+}
+
+// precisionDef precision declared by `col=name(p,s)`, it's "default" when it's absent
+func (c Column) precisionDef() string {
+	return lo.CoalesceOrEmpty(preReg.FindString(c.Property(colName).MustGet()), "default")
+}
+
+// Def generate column definition
+func (c Column) Def(db string) string {
+	return c.def(db, "")
+}
+
+// def generate column definition with the identity clause, then default value and check constraints
+func (c Column) def(db, identity string) string {
+	parts := []string{c.Type(db), lo.If(!c.Nullable(), "not null").Else(""), identity}
+	if v := c.Default(db); v.IsPresent() {
+		parts = append(parts, fmt.Sprintf("default %s", v.MustGet()))
+	}
+	if d := DialectOf(db); d.IsPresent() {
+		parts = append(parts, c.onUpdate(d.MustGet()), c.check(d.MustGet()), lo.If(len(c.doc) > 0, d.MustGet().Comment(c.doc)).Else(""))
 	}
-	return strings.TrimSpace(def)
+	return strings.Join(lo.Compact(parts), " ")
+}
+
+// Managed identify the column is maintained by database with `act` or `aut`
+func (c Column) Managed() bool {
+	return c.Property(colAct).IsPresent() || c.Property(colAut).IsPresent()
+}
+
+// onUpdate return the on update clause of `aut` column for the dialect supporting it
+func (c Column) onUpdate(d Dialect) string {
+	return lo.If(c.Property(colAut).IsPresent(), d.OnUpdate()).Else("")
 }
 
-// Key return "PK" or "FK" for ER diagram
+// Default return the sql literal of the default value in the platform
+func (c Column) Default(db string) mo.Option[string] {
+	v, d := c.Property(colDefault), DialectOf(db)
+	if c.Managed() && v.IsAbsent() && d.IsPresent() {
+		return mo.Some(d.MustGet().Literal("time.Time", "now"))
+	}
+	if v.IsAbsent() || d.IsAbsent() {
+		return mo.None[string]()
+	}
+	value := strings.Trim(v.MustGet(), "'")
+	if c.GoType() == "bool" {
+		b, _ := strconv.ParseBool(value)
+		value = strconv.FormatBool(b)
+	}
+	return mo.Some(d.MustGet().Literal(c.GoType(), value))
+}
+
+// check return the check constraints of the column, values of the enum are checked by constraint when
+// the dialect does not use native enum
+func (c Column) check(d Dialect) string {
+	var checks []string
+	if e := c.Enum(); e.IsPresent() && !e.MustGet().native(d) {
+		checks = append(checks, fmt.Sprintf("check (%s in (%s))", d.Ident(c.Name()), e.MustGet().Literals()))
+	}
+	if expr := c.Property(colCheck); expr.IsPresent() {
+		checks = append(checks, fmt.Sprintf("check (%s)", expr.MustGet()))
+	}
+	return strings.Join(checks, " ")
+}
+
+// validate validate the default value and check expression against the go type of the column
+func (c Column) validate() error {
+	for _, p := range []ColProperty{colAct, colAut} {
+		if c.Property(p).IsPresent() {
+			if c.GoType() != "time.Time" {
+				return fmt.Errorf("%s is only supported by time.Time", p)
+			}
+			if c.Property(colDefault).IsPresent() {
+				return fmt.Errorf("%s can not be used with default", p)
+			}
+		}
+	}
+	if c.Property(colAct).IsPresent() && c.Property(colAut).IsPresent() {
+		return fmt.Errorf("%s can not be used with %s", colAct, colAut)
+	}
+	if matches := preReg.FindStringSubmatch(c.Property(colName).MustGet()); len(matches) > 1 {
+		precision, n := c.precision(), c.types.precisions(c.GoType())
+		switch {
+		case n == 0:
+			return fmt.Errorf("precision %s is not supported by %s", matches[0], c.GoType())
+		case len(precision) != len(strings.Split(matches[1], ",")) || precision[0] <= 0 || lo.Min(precision) < 0:
+			return fmt.Errorf("invalid precision %s", matches[0])
+		case len(precision) > n:
+			return fmt.Errorf("precision %s has more than %d values for %s", matches[0], n, c.GoType())
+		case len(precision) == 2 && precision[1] > precision[0]:
+			return fmt.Errorf("scale %d is greater than precision %d", precision[1], precision[0])
+		}
+	}
+	if v := c.Property(colDefault); v.IsPresent() {
+		value, typ := strings.Trim(v.MustGet(), "'"), c.GoType()
+		var err error
+		switch {
+		case v.MustGet() == string(colDefault):
+			err = fmt.Errorf("default value is required")
+		case c.Enum().IsPresent():
+			if !slices.Contains(c.Enum().MustGet().values, value) {
+				err = fmt.Errorf("default value %s is not one of %s", value, strings.Join(c.Enum().MustGet().values, ", "))
+			}
+		case slices.Contains(intTypes, typ):
+			_, err = strconv.ParseInt(value, 10, 64)
+		case slices.Contains(uintTypes, typ):
+			_, err = strconv.ParseUint(value, 10, 64)
+		case slices.Contains(floatTypes, typ):
+			_, err = strconv.ParseFloat(value, 64)
+		case typ == "bool":
+			_, err = strconv.ParseBool(value)
+		case typ == "time.Time":
+			if !strings.EqualFold(value, "now") && !lo.ContainsBy(timeLayout, func(layout string) bool {
+				_, e := time.Parse(layout, value)
+				return e == nil
+			}) {
+				err = fmt.Errorf("invalid time %s, it should be 'now' or in format of %s", value, strings.Join(timeLayout, ", "))
+			}
+		case typ != "string":
+			err = fmt.Errorf("default value is not supported for %s", typ)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid default value %s: %w", v.MustGet(), err)
+		}
+	}
+	if expr := c.Property(colCheck); expr.IsPresent() {
+		depth := 0
+		for _, r := range expr.MustGet() {
+			depth += lo.If(r == '(', 1).ElseIf(r == ')', -1).Else(0)
+			if depth < 0 {
+				break
+			}
+		}
+		if depth != 0 {
+			return fmt.Errorf("unbalanced parentheses in check %s", expr.MustGet())
+		}
+		if !regexp.MustCompile(fmt.Sprintf(`\b%s\b`, regexp.QuoteMeta(c.Name()))).MatchString(expr.MustGet()) {
+			return fmt.Errorf("check %s does not reference column %s", expr.MustGet(), c.Name())
+		}
+	}
+	return nil
+}
+
+// Constraint return keys, default value and check expression of the column for ER diagram
+func (c Column) Constraint() mo.Option[string] {
+	var items []string
+	if c.Property(colPK).IsPresent() {
+		items = append(items, "PK")
+	}
+	if c.Property(colRef).IsPresent() {
+		items = append(items, "FK")
+	}
+	if v := c.Property(colDefault); v.IsPresent() {
+		items = append(items, strconv.Quote(fmt.Sprintf("default %s", v.MustGet())))
+	}
+	if expr := c.Property(colCheck); expr.IsPresent() {
+		items = append(items, strconv.Quote(fmt.Sprintf("check %s", expr.MustGet())))
+	}
+	switch len(items) {
+	case 0:
+		return mo.None[string]()
+	case 1:
+		return mo.Some(items[0])
+	default:
+		return mo.Some(fmt.Sprintf("[%s]", strings.Join(items, "; ")))
+	}
+}
+
+// Keys return "PK", "FK" or both of them
+func (c Column) Keys() []string {
+	var keys []string
+	if c.Property(colPK).IsPresent() {
+		keys = append(keys, "PK")
+	}
+	if c.Property(colRef).IsPresent() {
+		keys = append(keys, "FK")
+	}
+	return keys
+}
+
+// Key return "PK", "FK" or both of them for ER diagram
 func (c Column) Key() mo.Option[string] {
-	return lo.If(c.Property(colPK).IsPresent(), mo.Some("PK")).
-		ElseIf(c.Property(colRef).IsPresent(), mo.Some("FK")).Else(mo.None[string]())
+	pk, fk := c.Property(colPK).IsPresent(), c.Property(colRef).IsPresent()
+	return lo.If(pk && fk, mo.Some("[PK; FK]")).
+		ElseIf(pk, mo.Some("PK")).
+		ElseIf(fk, mo.Some("FK")).Else(mo.None[string]())
 }
 
 type Table struct {
@@ -140,6 +421,36 @@ type Table struct {
 	name    string
 	columns []Column
 	pkg     *packages.Package
+	doc     string
+	join    bool
+	// pos declaration of the entity type, it's used to report problems
+	pos token.Pos
+	// datasource of the table, table without datasource is shared by all the datasources
+	datasource string
+}
+
+// problem problem of the table, it's reported at the column when the column is present
+func (t Table) problem(c mo.Option[Column], format string, args ...any) Problem {
+	pos := t.pos
+	if column, ok := c.Get(); ok && column.pos.IsValid() {
+		pos = column.pos
+	}
+	return Problem{Position: position(t.pkg, pos), Entity: t.entity, Message: fmt.Sprintf(format, args...)}
+}
+
+// Datasource datasource of the table declared by directive `//dbo:datasource <name>`
+func (t Table) Datasource() string {
+	return t.datasource
+}
+
+// Join identify the table is a join table synthesized for many-to-many relationship
+func (t Table) Join() bool {
+	return t.join
+}
+
+// Doc doc comment of the entity
+func (t Table) Doc() string {
+	return t.doc
 }
 
 // Entity returns the entity name of the table
@@ -180,19 +491,81 @@ func (t Table) Name() string {
 	return t.name
 }
 
-// MaxWidth max width of the column name, for schema generation
-func (t Table) MaxWidth() int {
+// MaxWidth max width of the column name in the platform, for schema generation
+func (t Table) MaxWidth(db string) int {
 	return lo.Max(lo.Map(t.columns, func(item Column, _ int) int {
-		return len(item.Name())
+		return len(DialectOf(db).MustGet().Ident(item.Name()))
 	})) + 1
 }
 
-// PK return primary key of the table
+// pkColumns return primary key columns of the table. columns of a composite primary key are in order of
+// `pk=<n>` or in column order when no one has the ordinal
+func (t Table) pkColumns() mo.Result[[]Column] {
+	pks := lo.Filter(t.Columns(), func(c Column, _ int) bool {
+		return c.Property(colPK).IsPresent()
+	})
+	if len(pks) == 0 {
+		return mo.Err[[]Column](fmt.Errorf("%s: no primary key", t.entity))
+	}
+	ordinals := lo.FilterMap(pks, func(c Column, _ int) (string, bool) {
+		return c.Property(colPK).MustGet(), c.Property(colPK).MustGet() != string(colPK)
+	})
+	if len(ordinals) == 0 {
+		return mo.Ok(pks)
+	}
+	if len(ordinals) != len(pks) {
+		return mo.Err[[]Column](fmt.Errorf("%s: ambiguous primary key, ordinal is required for all columns", t.entity))
+	}
+	if len(lo.Uniq(ordinals)) != len(ordinals) {
+		return mo.Err[[]Column](fmt.Errorf("%s: ambiguous primary key, duplicated ordinal", t.entity))
+	}
+	for _, ordinal := range ordinals {
+		if _, err := strconv.Atoi(ordinal); err != nil {
+			return mo.Err[[]Column](fmt.Errorf("%s: invalid primary key ordinal %s", t.entity, ordinal))
+		}
+	}
+	slices.SortStableFunc(pks, func(a, b Column) int {
+		return lo.Must(strconv.Atoi(a.Property(colPK).MustGet())) - lo.Must(strconv.Atoi(b.Property(colPK).MustGet()))
+	})
+	return mo.Ok(pks)
+}
+
+// PKColumns return primary key columns of the table
+func (t Table) PKColumns() []Column {
+	return t.pkColumns().OrEmpty()
+}
+
+// PK return comma separated primary key column names of the table, for schema generation
 func (t Table) PK() string {
-	pk := mo.TupleToOption(lo.Find(t.Columns(), func(c Column) bool {
-		return c.Key().IsPresent() && c.Key().MustGet() == "PK"
-	}))
-	return pk.MustGet().Name()
+	return strings.Join(lo.Map(t.PKColumns(), func(c Column, _ int) string {
+		return c.Name()
+	}), ", ")
+}
+
+// Identity identify the column is an auto increment column, only single int64 primary key which is
+// not a reference is auto increment
+func (t Table) Identity(c Column) bool {
+	pks := t.PKColumns()
+	return len(pks) == 1 && pks[0].A == c.A && c.AttrType() == "int64" && c.Ref().IsAbsent()
+}
+
+// ColumnDef column definition with auto increment, for schema generation
+func (t Table) ColumnDef(c Column, db string) string {
+	return c.def(db, lo.If(t.Identity(c), DialectOf(db).MustGet().Identity()).Else(""))
+}
+
+// Managed return the columns maintained by database, inserts and updates skip them
+func (t Table) Managed() []Column {
+	return lo.Filter(t.Columns(), func(c Column, _ int) bool {
+		return c.Managed()
+	})
+}
+
+// Triggers return the triggers to maintain the `aut` columns of the table
+func (t Table) Triggers(db string) []string {
+	return lo.FlatMap(t.Columns(), func(c Column, _ int) []string {
+		return lo.If(c.Property(colAut).IsPresent(), DialectOf(db).MustGet().Trigger(t, c)).Else(nil)
+	})
 }
 
 // Column return the corresponding column of the attribute
@@ -202,27 +575,400 @@ func (t Table) Column(attrName string) mo.Option[Column] {
 	}))
 }
 
+// Index database index of the table, columns share the same index name compose a composite index
+type Index struct {
+	name    string
+	unique  bool
+	columns []string
+}
+
+// Name index name
+func (idx Index) Name() string {
+	return idx.name
+}
+
+// Unique identify the index is unique or not
+func (idx Index) Unique() bool {
+	return idx.unique
+}
+
+// Columns return comma separated column names of the index, for schema generation
+func (idx Index) Columns() string {
+	return strings.Join(idx.columns, ", ")
+}
+
+// indexNames return the index names of the column for property `idx` or `uniq`. the property without value
+// means a single column index with default name, otherwise the value is comma separated index names
+func (c Column) indexNames(entity string, p ColProperty) []string {
+	v := c.Property(p)
+	if v.IsAbsent() {
+		return []string{}
+	}
+	if v.MustGet() == string(p) {
+		return []string{fmt.Sprintf("%s_%s_%s", lo.If(p == colIdx, "idx").Else("uk"), lo.SnakeCase(entity), c.Name())}
+	}
+	return lo.Map(strings.Split(v.MustGet(), ","), func(name string, _ int) string {
+		return strings.TrimSpace(name)
+	})
+}
+
+// unique identify the column is the single column primary key or single column unique index of the table
+func (t Table) unique(c Column) bool {
+	if pks := t.PKColumns(); len(pks) == 1 && pks[0].A == c.A {
+		return true
+	}
+	return lo.ContainsBy(t.Indexes(), func(idx Index) bool {
+		return idx.unique && len(idx.columns) == 1 && idx.columns[0] == c.Name()
+	})
+}
+
+// Indexes return all the indexes of the table, columns with the same index name compose a composite index
+func (t Table) Indexes() []Index {
+	var indexes []Index
+	for _, c := range t.Columns() {
+		for _, p := range []ColProperty{colIdx, colUniq} {
+			for _, name := range c.indexNames(t.entity, p) {
+				if _, i, ok := lo.FindIndexOf(indexes, func(idx Index) bool {
+					return idx.name == name
+				}); ok {
+					indexes[i].columns = append(indexes[i].columns, c.Name())
+				} else {
+					indexes = append(indexes, Index{name: name, unique: p == colUniq, columns: []string{c.Name()}})
+				}
+			}
+		}
+	}
+	return indexes
+}
+
+// ForeignKey foreign key constraint of the table, it's built from column property `ref`
+type ForeignKey struct {
+	name      string
+	column    string
+	refTable  string
+	refColumn string
+	onDelete  mo.Option[string]
+	onUpdate  mo.Option[string]
+}
+
+// Name constraint name of the foreign key
+func (fk ForeignKey) Name() string {
+	return fk.name
+}
+
+// Column name of the referencing column
+func (fk ForeignKey) Column() string {
+	return fk.column
+}
+
+// RefTable name of the referenced table
+func (fk ForeignKey) RefTable() string {
+	return fk.refTable
+}
+
+// RefColumn name of the referenced column
+func (fk ForeignKey) RefColumn() string {
+	return fk.refColumn
+}
+
+// Actions referential actions of the foreign key, for schema generation
+func (fk ForeignKey) Actions() string {
+	var actions []string
+	if fk.onDelete.IsPresent() {
+		actions = append(actions, fmt.Sprintf("ON DELETE %s", strings.ToUpper(fk.onDelete.MustGet())))
+	}
+	if fk.onUpdate.IsPresent() {
+		actions = append(actions, fmt.Sprintf("ON UPDATE %s", strings.ToUpper(fk.onUpdate.MustGet())))
+	}
+	return lo.If(len(actions) > 0, " "+strings.Join(actions, " ")).Else("")
+}
+
+// Cluster tables of the same go package, they are grouped as a container in ER diagram
+type Cluster struct {
+	name   string
+	path   string
+	tables []Table
+}
+
+// Name key of the cluster in ER diagram, it's empty for tables without package
+func (c Cluster) Name() string {
+	return c.name
+}
+
+// Path go package path of the tables
+func (c Cluster) Path() string {
+	return c.path
+}
+
+// Tables tables of the package
+func (c Cluster) Tables() []Table {
+	return c.tables
+}
+
+// Relation relationship between two tables, it's built from column property `ref`. the referencing column is the
+// source, a reference on unique column is one-to-one, otherwise many-to-one, and a nullable reference is optional
+type Relation struct {
+	table      string
+	column     string
+	cluster    string
+	refTable   string
+	refColumn  string
+	refCluster string
+	unique     bool
+	optional   bool
+}
+
+// Table name of the referencing table
+func (r Relation) Table() string {
+	return r.table
+}
+
+// Column attribute of the referencing column
+func (r Relation) Column() string {
+	return r.column
+}
+
+// RefTable name of the referenced table
+func (r Relation) RefTable() string {
+	return r.refTable
+}
+
+// RefColumn attribute of the referenced column
+func (r Relation) RefColumn() string {
+	return r.refColumn
+}
+
+// Source path of the referencing column in d2 ER diagram
+func (r Relation) Source() string {
+	return strings.Join(lo.Compact([]string{r.cluster, r.table, r.column}), ".")
+}
+
+// Target path of the referenced column in d2 ER diagram
+func (r Relation) Target() string {
+	return strings.Join(lo.Compact([]string{r.refCluster, r.refTable, r.refColumn}), ".")
+}
+
+// Cardinality one-to-one or many-to-one
+func (r Relation) Cardinality() string {
+	return lo.If(r.unique, "one-to-one").Else("many-to-one")
+}
+
+// Optional identify the referencing row may not have referenced row
+func (r Relation) Optional() bool {
+	return r.optional
+}
+
+// Label label of the relationship in ER diagram
+func (r Relation) Label() string {
+	return lo.If(r.optional, "optional ").Else("") + r.Cardinality()
+}
+
+// SourceArrowhead crow's foot shape at the referencing side
+func (r Relation) SourceArrowhead() string {
+	return lo.If(r.unique, "cf-one").Else("cf-many")
+}
+
+// TargetArrowhead crow's foot shape at the referenced side
+func (r Relation) TargetArrowhead() string {
+	return lo.If(r.optional, "cf-one").Else("cf-one-required")
+}
+
+// Notation crow's foot notation of the relationship from the referencing side, for mermaid and plantuml
+func (r Relation) Notation() string {
+	return lo.If(r.unique, "|o").Else("}o") + "--" + lo.If(r.optional, "o|").Else("||")
+}
+
+// ArrowTail graphviz arrow shape at the referencing side
+func (r Relation) ArrowTail() string {
+	return lo.If(r.unique, "teeodot").Else("crowodot")
+}
+
+// ArrowHead graphviz arrow shape at the referenced side
+func (r Relation) ArrowHead() string {
+	return lo.If(r.optional, "teeodot").Else("teetee")
+}
+
 type DBO struct {
 	g graph.Graph[string, Table]
+	// types custom type mappings of the project, they are used to restore the snapshot
+	types typeMappings
 }
 
-// Tables return all the tables of the project
+// Tables return all the tables of the project in topological order, referenced tables come first.
+// Tables at the same level are sorted by entity name, self reference is ignored.
 func (dbo DBO) Tables() []Table {
-	return lo.Map(lo.Keys(mo.TupleToResult(dbo.g.PredecessorMap()).MustGet()),
-		func(item string, index int) Table {
-			return mo.TupleToResult(dbo.g.Vertex(item)).MustGet()
+	adjacency := mo.TupleToResult(dbo.g.AdjacencyMap()).MustGet()
+	var ordered []string
+	visited := map[string]bool{}
+	for len(ordered) < len(adjacency) {
+		rest := lo.Filter(lo.Keys(adjacency), func(entity string, _ int) bool {
+			return !visited[entity]
+		})
+		ready := lo.Filter(rest, func(entity string, _ int) bool {
+			return lo.EveryBy(lo.Keys(adjacency[entity]), func(ref string) bool {
+				return ref == entity || visited[ref]
+			})
 		})
+		// reference cycle is rejected by build, it's only a guard
+		ready = lo.If(len(ready) > 0, ready).Else(rest)
+		slices.Sort(ready)
+		for _, entity := range ready {
+			visited[entity] = true
+		}
+		ordered = append(ordered, ready...)
+	}
+	return lo.Map(ordered, func(item string, _ int) Table {
+		return mo.TupleToResult(dbo.g.Vertex(item)).MustGet()
+	})
 }
 
-// Edges return all the relationships among the table
-func (dbo DBO) Edges() []string {
-	if edges, err := dbo.g.Edges(); err != nil {
-		return []string{}
-	} else {
-		return lo.Map(edges, func(item graph.Edge[string], _ int) string {
-			return item.Properties.Attributes["ref"]
+// ForeignKeys return foreign keys of the entity
+func (dbo DBO) ForeignKeys(entity string) []ForeignKey {
+	t := dbo.Table(entity)
+	return lo.FilterMap(t.Columns(), func(c Column, _ int) (ForeignKey, bool) {
+		if c.Ref().IsAbsent() {
+			return ForeignKey{}, false
+		}
+		referred := strings.Split(c.Ref().MustGet(), ".")
+		rt := dbo.Table(referred[0])
+		return ForeignKey{
+			name:      fmt.Sprintf("fk_%s_%s", lo.SnakeCase(entity), c.Name()),
+			column:    c.Name(),
+			refTable:  rt.Name(),
+			refColumn: rt.Column(referred[1]).MustGet().Name(),
+			onDelete:  c.Property(colOnDelete),
+			onUpdate:  c.Property(colOnUpdate),
+		}, true
+	})
+}
+
+// Enums return all the enums used by the tables in name order
+func (dbo DBO) Enums() []Enum {
+	var enums []Enum
+	for _, t := range dbo.Tables() {
+		for _, c := range t.columns {
+			if e := c.Enum(); e.IsPresent() && !lo.ContainsBy(enums, func(item Enum) bool {
+				return item.name == e.MustGet().name
+			}) {
+				enums = append(enums, e.MustGet())
+			}
+		}
+	}
+	slices.SortFunc(enums, func(a, b Enum) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return enums
+}
+
+// nativeEnums return the enums created as types in the dialect
+func (dbo DBO) nativeEnums(d Dialect) []Enum {
+	return lo.Filter(dbo.Enums(), func(e Enum, _ int) bool {
+		return e.native(d)
+	})
+}
+
+// Clusters return tables grouped by go package in package path order. the cluster name is the package name,
+// it's the package path in snake case when the name is used by another package
+func (dbo DBO) Clusters() []Cluster {
+	var clusters []Cluster
+	for _, t := range dbo.Tables() {
+		name, path := "", ""
+		if t.pkg != nil {
+			name, path = t.pkg.Name, t.pkg.PkgPath
+		}
+		if _, i, ok := lo.FindIndexOf(clusters, func(c Cluster) bool {
+			return c.path == path
+		}); ok {
+			clusters[i].tables = append(clusters[i].tables, t)
+			continue
+		}
+		if lo.ContainsBy(clusters, func(c Cluster) bool {
+			return c.name == name
+		}) {
+			name = lo.SnakeCase(path)
+		}
+		clusters = append(clusters, Cluster{name: name, path: path, tables: []Table{t}})
+	}
+	slices.SortStableFunc(clusters, func(a, b Cluster) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return clusters
+}
+
+// Relations return all the relationships among the tables, they are derived from the column property `ref`
+func (dbo DBO) Relations() []Relation {
+	clusters := dbo.Clusters()
+	clusterOf := func(t Table) string {
+		cluster, _ := lo.Find(clusters, func(cluster Cluster) bool {
+			return lo.ContainsBy(cluster.tables, func(item Table) bool {
+				return item.entity == t.entity
+			})
 		})
+		return cluster.name
+	}
+	var relations []Relation
+	for _, t := range dbo.Tables() {
+		for _, c := range t.columns {
+			if ref := c.Ref(); ref.IsPresent() {
+				referred := strings.Split(ref.MustGet(), ".")
+				rt := dbo.Table(referred[0])
+				relations = append(relations, Relation{
+					table:      t.name,
+					column:     c.A,
+					cluster:    clusterOf(t),
+					refTable:   rt.name,
+					refColumn:  rt.Column(referred[1]).MustGet().A,
+					refCluster: clusterOf(rt),
+					unique:     t.unique(c),
+					optional:   c.Nullable(),
+				})
+			}
+		}
+	}
+	return relations
+}
+
+// datasources return the datasources declared by the entities in name order
+func (dbo DBO) datasources() []string {
+	names := lo.Uniq(lo.FilterMap(dbo.Tables(), func(t Table, _ int) (string, bool) {
+		return t.datasource, len(t.datasource) > 0
+	}))
+	slices.Sort(names)
+	return names
+}
+
+// datasource return the tables of the datasource, tables without datasource are shared by all the datasources
+func (dbo DBO) datasource(name string) DBO {
+	g := graph.New[string, Table](func(table Table) string {
+		return table.entity
+	}, graph.Directed())
+	for _, t := range dbo.Tables() {
+		if len(t.datasource) == 0 || t.datasource == name {
+			g.AddVertex(t)
+		}
+	}
+	for entity, refs := range mo.TupleToResult(dbo.g.AdjacencyMap()).MustGet() {
+		for ref := range refs {
+			// edge is ignored when either table is not in the datasource
+			g.AddEdge(entity, ref)
+		}
+	}
+	return DBO{g: g, types: dbo.types}
+}
+
+// each call fn with the tables of every datasource and path/<datasource>, or with all the tables and
+// the path when no entity declares datasource
+func (dbo DBO) each(path string, fn func(name string, dbo DBO, path string) error) error {
+	names := dbo.datasources()
+	if len(names) == 0 {
+		return fn("", dbo, path)
+	}
+	for _, name := range names {
+		if err := fn(name, dbo.datasource(name), filepath.Join(path, name)); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // Table get table of the entity
@@ -231,55 +977,236 @@ func (dbo DBO) Table(entity string) Table {
 	return t
 }
 
-// ER generate ER diagram of the tables
-func (dbo DBO) ER(path string) error {
-	err := os.MkdirAll(path, 0755)
-	if err == nil {
-		file := mo.TupleToResult(os.Create(filepath.Join(path, "er.d2")))
-		if file.IsError() {
-			return file.Error()
+// ERFormats return the supported formats of ER diagram
+func ERFormats() []string {
+	return []string{"d2", "dot", "mermaid", "plantuml"}
+}
+
+// erTemplate return template and file extension of the ER diagram format
+func erTemplate(format string) mo.Result[lo.Tuple2[string, string]] {
+	switch format {
+	case "d2":
+		return mo.Ok(lo.T2(erTmpl, "d2"))
+	case "dot":
+		return mo.Ok(lo.T2(erDotTmpl, "dot"))
+	case "mermaid":
+		return mo.Ok(lo.T2(erMermaidTmpl, "mmd"))
+	case "plantuml":
+		return mo.Ok(lo.T2(erPlantUMLTmpl, "puml"))
+	default:
+		return mo.Err[lo.Tuple2[string, string]](fmt.Errorf("unsupported ER diagram format %s, supported formats are %s", format, strings.Join(ERFormats(), ", ")))
+	}
+}
+
+// diagram return the ER diagram source of the format and the file extension
+func (dbo DBO) diagram(format string) mo.Result[lo.Tuple2[[]byte, string]] {
+	et := erTemplate(format)
+	if et.IsError() {
+		return mo.Err[lo.Tuple2[[]byte, string]](et.Error())
+	}
+	fns := template.FuncMap{
+		"quote": strconv.Quote,
+		"join":  strings.Join,
+		// ident return the go type as an identifier, mermaid does not allow '.' and '*' in attribute type
+		"ident": func(typ string) string {
+			return identReg.ReplaceAllString(strings.TrimPrefix(typ, "*"), "_")
+		},
+		// comment return the text in double quotes, double quotes in the text are replaced with single quotes
+		"comment": func(text string) string {
+			return fmt.Sprintf(`"%s"`, strings.ReplaceAll(text, `"`, "'"))
+		},
+	}
+	tmpl := mo.TupleToResult(template.New(format).Funcs(fns).Parse(et.MustGet().A))
+	if tmpl.IsError() {
+		return mo.Err[lo.Tuple2[[]byte, string]](tmpl.Error())
+	}
+	var buf bytes.Buffer
+	if err := tmpl.MustGet().Execute(&buf, dbo); err != nil {
+		return mo.Err[lo.Tuple2[[]byte, string]](err)
+	}
+	return mo.Ok(lo.T2(buf.Bytes(), et.MustGet().B))
+}
+
+// ER generate ER diagram of the tables in the formats, it's d2 when no format is specified
+func (dbo DBO) ER(path string, formats ...string) error {
+	return dbo.each(path, func(_ string, dbo DBO, path string) error {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
 		}
-		defer file.MustGet().Close()
-		tmpl := mo.TupleToResult(template.New("er").Parse(erTmpl))
-		return lo.IfF(tmpl.IsError(), func() error {
-			return tmpl.Error()
-		}).ElseF(func() error {
-			return tmpl.MustGet().Execute(file.MustGet(), dbo)
-		})
+		for _, format := range lo.Uniq(lo.If(len(formats) > 0, formats).Else([]string{"d2"})) {
+			diagram := dbo.diagram(format)
+			if diagram.IsError() {
+				return diagram.Error()
+			}
+			if err := os.WriteFile(filepath.Join(path, fmt.Sprintf("er.%s", diagram.MustGet().B)), diagram.MustGet().A, 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dialect return the dialect of the platform, all the columns must have type mapping in the dialect
+func (dbo DBO) dialect(platform string) mo.Result[Dialect] {
+	d := DialectOf(platform)
+	if d.IsAbsent() {
+		return mo.Err[Dialect](fmt.Errorf("unsupported platform %s", platform))
 	}
-	return err
+	for _, t := range dbo.Tables() {
+		for _, c := range t.columns {
+			if c.types.sqlType(d.MustGet(), c.GoType()).IsAbsent() {
+				return mo.Err[Dialect](fmt.Errorf("%s.%s: can not find %s type mapping for %s", t.entity, c.A, platform, c.AttrType()))
+			}
+		}
+	}
+	return mo.Ok(d.MustGet())
 }
 
-// Schema generate schema of the tables
-func (dbo DBO) Schema(path string) error {
-	if err := os.MkdirAll(path, 0755); err != nil {
-		return err
+// schema return schema template of the platform
+func (dbo DBO) schema(platform string) mo.Result[*template.Template] {
+	d := dbo.dialect(platform)
+	if d.IsError() {
+		return mo.Err[*template.Template](d.Error())
 	}
-	for _, platform := range Platforms() {
-		file := mo.TupleToResult(os.Create(filepath.Join(path, fmt.Sprintf("schema-%s.sql", platform))))
-		if file.IsError() {
-			return file.Error()
+	fns := template.FuncMap{
+		"db": func() string {
+			return platform
+		},
+		"dialect": func() Dialect {
+			return d.MustGet()
+		},
+		"fks": dbo.ForeignKeys,
+		"enums": func() []Enum {
+			return dbo.nativeEnums(d.MustGet())
+		},
+		"comments": func(t Table) []string {
+			return comments(d.MustGet(), t)
+		},
+		"ident": d.MustGet().Ident,
+		"pk": func(t Table) string {
+			return idents(d.MustGet(), lo.Map(t.PKColumns(), func(c Column, _ int) string {
+				return c.Name()
+			}))
+		},
+		"columns": func(idx Index) string {
+			return idents(d.MustGet(), idx.columns)
+		},
+	}
+	return mo.TupleToResult(template.New(platform).Funcs(fns).Parse(schemaTmpl))
+}
+
+// idents comma separated identifiers of the names in the dialect
+func idents(d Dialect, names []string) string {
+	return strings.Join(lo.Map(names, func(name string, _ int) string {
+		return d.Ident(name)
+	}), ", ")
+}
+
+// comments return the statements to comment on the table and its columns for the dialect which does not
+// support comment clause
+func comments(d Dialect, t Table) []string {
+	if d.InlineComment() {
+		return nil
+	}
+	var stmts []string
+	if len(t.doc) > 0 {
+		stmts = append(stmts, d.CommentOn(t, mo.None[Column]())...)
+	}
+	for _, c := range t.Columns() {
+		if len(c.doc) > 0 {
+			stmts = append(stmts, d.CommentOn(t, mo.Some(c))...)
 		}
-		fns := template.FuncMap{
-			"db": func() string {
-				return platform
-			},
+	}
+	return stmts
+}
+
+// drop statements to drop all the tables in reverse topological order, referencing tables are dropped before
+// referenced tables, then native enums
+func (dbo DBO) drop(d Dialect) []string {
+	var stmts []string
+	for _, t := range lo.Reverse(dbo.Tables()) {
+		for _, c := range t.Columns() {
+			if c.Property(colAut).IsPresent() {
+				stmts = append(stmts, d.DropTrigger(t, c)...)
+			}
 		}
-		tmpl := mo.TupleToResult(template.New(platform).Funcs(fns).Parse(schemaTmpl))
-		if tmpl.IsError() {
-			return tmpl.Error()
+		stmts = append(stmts, fmt.Sprintf("drop table %s;", d.Ident(t.Name())))
+	}
+	return append(stmts, lo.Map(dbo.nativeEnums(d), func(e Enum, _ int) string {
+		return d.DropEnum(e)
+	})...)
+}
+
+// Schema generate schema of the tables as schema-<platform>.sql and the drop script in reverse order
+// as drop-<platform>.sql. scripts of a datasource are generated for the platforms of the datasource
+func (dbo DBO) Schema(path string) error {
+	return dbo.each(path, func(name string, dbo DBO, path string) error {
+		platforms := platformsOf(name)
+		if platforms.IsError() {
+			return platforms.Error()
 		}
-		if err := tmpl.MustGet().Execute(file.MustGet(), dbo); err != nil {
+		if err := os.MkdirAll(path, 0755); err != nil {
 			return err
 		}
-		file.MustGet().Close()
+		for _, platform := range platforms.MustGet() {
+			file := mo.TupleToResult(os.Create(filepath.Join(path, fmt.Sprintf("schema-%s.sql", platform))))
+			if file.IsError() {
+				return file.Error()
+			}
+			tmpl := dbo.schema(platform)
+			if tmpl.IsError() {
+				return tmpl.Error()
+			}
+			if err := tmpl.MustGet().Execute(file.MustGet(), dbo); err != nil {
+				return err
+			}
+			file.MustGet().Close()
+			drop := strings.Join(dbo.drop(DialectOf(platform).MustGet()), "\n") + "\n"
+			if err := os.WriteFile(filepath.Join(path, fmt.Sprintf("drop-%s.sql", platform)), []byte(drop), 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// platformsOf return platforms of the datasource, it's all the platforms for the unnamed datasource
+func platformsOf(name string) mo.Result[[]string] {
+	if len(name) == 0 {
+		return mo.Ok(Platforms())
 	}
-	return nil
+	if platforms := Datasources()[name]; len(platforms) > 0 {
+		return mo.Ok(platforms)
+	}
+	return mo.Err[[]string](fmt.Errorf("datasource %s is not configured", name))
 }
 
 func (dbo DBO) Columns(path string) error {
+	return dbo.each(path, func(name string, dbo DBO, path string) error {
+		platforms := platformsOf(name)
+		if platforms.IsError() {
+			return platforms.Error()
+		}
+		// names are kept raw when the datasource has several platforms, as quoting differs between them
+		if len(platforms.MustGet()) != 1 {
+			return dbo.columns(path, mo.None[Dialect]())
+		}
+		return dbo.columns(path, DialectOf(platforms.MustGet()[0]))
+	})
+}
+
+// columns generate column definitions of every table in path/columns/<entity>, names are quoted by the dialect
+// when it's present and they are required to be quoted
+func (dbo DBO) columns(path string, d mo.Option[Dialect]) error {
 	fMap := template.FuncMap{
 		"ToLower": strings.ToLower,
+		"quote":   strconv.Quote,
+		"ident": func(name string) string {
+			if dialect, ok := d.Get(); ok {
+				return dialect.Ident(name)
+			}
+			return name
+		},
 	}
 	for _, table := range dbo.Tables() {
 		dir := filepath.Join(path, "columns", strings.ToLower(table.entity))
@@ -327,6 +1254,23 @@ func Build() mo.Result[DBO] {
 	if len(pkgs) == 0 {
 		return mo.Err[DBO](fmt.Errorf("no entities found"))
 	}
+	docs := docComments(pkgs)
+	datasources := directives(pkgs)
+	naming := loadNaming()
+	if naming.IsError() {
+		return mo.Err[DBO](naming.Error())
+	}
+	var problems Problems
+	// custom type mappings are loaded once, problems of them are reported at the position in the configuration
+	mappings := customTypes()
+	if diagnostics := (Diagnostics{}); mappings.IsError() && errors.As(mappings.Error(), &diagnostics) {
+		problems = append(problems, lo.Map(diagnostics, func(d Diagnostic, _ int) Problem {
+			file := lo.If(d.File == envFile, d.File).Else(filepath.Join(app.RootDir(), d.File))
+			return Problem{Position: token.Position{Filename: file, Line: d.Line}, Message: d.Message}
+		})...)
+	} else if mappings.IsError() {
+		return mo.Err[DBO](mappings.Error())
+	}
 	for _, pkg := range pkgs {
 		for _, syntax := range pkg.Syntax {
 			ast.Inspect(syntax, func(node ast.Node) bool {
@@ -343,121 +1287,513 @@ func Build() mo.Result[DBO] {
 						}
 						if named.Obj().Exported() && implements(named, iEntity) {
 							if str, ok := named.Underlying().(*types.Struct); ok {
-								columns := parseColumn(str, iEntity)
-								if columns.IsError() {
-									err = fmt.Errorf("type %s: %s", named.Obj().Name(), columns.Error().Error())
-									return false
+								entity := named.Obj().Name()
+								columns, columnProblems := parseColumn(pkg, str, iEntity, docs, naming.MustGet(), mappings.OrEmpty())
+								if len(columns) == 0 && len(columnProblems) == 0 {
+									columnProblems = append(columnProblems, Problem{Position: position(pkg, named.Obj().Pos()), Message: "no columns found"})
 								}
+								problems = append(problems, lo.Map(columnProblems, func(p Problem, _ int) Problem {
+									p.Entity = entity
+									return p
+								})...)
 								for _, stmt := range funcDecl.Body.List {
 									if retStmt, ok := stmt.(*ast.ReturnStmt); ok {
 										if len(retStmt.Results) > 0 {
+											name := tableName(pkg, retStmt.Results[len(retStmt.Results)-1])
+											if name.IsError() {
+												problem, _ := name.Error().(Problem)
+												problem.Entity = entity
+												problems = append(problems, problem)
+												break
+											}
+											// table name is derived from the entity when it's empty
+											if len(name.MustGet()) == 0 {
+												if naming.MustGet().IsAbsent() {
+													problems = append(problems, Problem{Position: position(pkg, retStmt.Pos()), Entity: entity, Message: "empty table name"})
+													break
+												}
+												name = mo.Ok(naming.MustGet().MustGet().Table(entity))
+											}
 											dag.AddVertex(
-												Table{entity: named.Obj().Name(),
-													name:    exprToString(retStmt.Results[len(retStmt.Results)-1]),
-													pkg:     pkg,
-													columns: columns.MustGet()})
+												Table{entity: entity,
+													name:       name.MustGet(),
+													pkg:        pkg,
+													pos:        named.Obj().Pos(),
+													doc:        docs[named.Obj().Pos()],
+													columns:    columns,
+													datasource: lo.CoalesceOrEmpty(datasource(funcDecl.Doc), datasources[named.Obj().Pos()])})
 											break
 										}
 									}
 								}
-								//@todo pk must exists
 							}
 						}
 					}
 				}
 				return true
 			})
-			if err != nil {
-				return mo.Err[DBO](err)
+		}
+	}
+	// entities with problems are still added, so the problems of the relationships are reported at once
+	dbo := build(dag)
+	if dbo.IsError() {
+		var buildProblems Problems
+		if !errors.As(dbo.Error(), &buildProblems) {
+			return dbo
+		}
+		problems = append(problems, buildProblems...)
+	} else {
+		problems = append(problems, dbo.MustGet().identifiers(Datasources(), quoting())...)
+	}
+	if len(problems) > 0 {
+		return mo.Err[DBO](problems.sort())
+	}
+	return mo.Ok(DBO{g: dbo.MustGet().g, types: mappings.MustGet()})
+}
+
+// joinTable synthesize the join table of many-to-many relationship between the entity and the target,
+// it has a composite primary key of the primary keys of both tables, and rows are deleted with them
+func joinTable(g graph.Graph[string, Table], t Table, target string) mo.Result[Table] {
+	tt := mo.TupleToResult(g.Vertex(target))
+	if tt.IsError() {
+		return mo.Err[Table](fmt.Errorf("%s: can not find m2m type %s", t.entity, target))
+	}
+	var columns []Column
+	for i, table := range []Table{t, tt.MustGet()} {
+		pks := table.pkColumns()
+		if pks.IsError() {
+			return mo.Err[Table](pks.Error())
+		}
+		if len(pks.MustGet()) != 1 {
+			return mo.Err[Table](fmt.Errorf("%s: m2m with %s requires single column primary key of %s", t.entity, target, table.entity))
+		}
+		pk := pks.MustGet()[0]
+		name := lo.If(i == 1 && t.entity == target, "related_").Else("") + fmt.Sprintf("%s_%s", lo.SnakeCase(table.entity), pk.Name())
+		columns = append(columns, Column{
+			A:     lo.PascalCase(name),
+			B:     strings.TrimPrefix(pk.B, "*"),
+			C:     fmt.Sprintf("%s=%s%s;%s=%d;%s=%s.%s;%s=cascade", colName, name, preReg.FindString(pk.Property(colName).MustGet()), colPK, i+1, colRef, table.entity, pk.A, colOnDelete),
+			enum:  pk.enum,
+			types: pk.types,
+		})
+	}
+	entity := t.entity + target
+	ds := lo.CoalesceOrEmpty(t.datasource, tt.MustGet().datasource)
+	return mo.Ok(Table{entity: entity, name: lo.SnakeCase(entity), columns: columns, pkg: t.pkg, join: true, datasource: ds})
+}
+
+// cycle return the problem when tables reference each other directly or indirectly, as they can not be created or
+// dropped in order. self reference is allowed. the problem is reported on the first reference of the cycle
+func cycle(g graph.Graph[string, Table]) mo.Option[Problem] {
+	components := mo.TupleToResult(graph.StronglyConnectedComponents(g)).MustGet()
+	components = lo.Filter(components, func(component []string, _ int) bool {
+		return len(component) > 1
+	})
+	if len(components) == 0 {
+		return mo.None[Problem]()
+	}
+	component := lo.MinBy(components, func(a, b []string) bool {
+		return slices.Min(a) < slices.Min(b)
+	})
+	slices.Sort(component)
+	var refs []string
+	var first mo.Option[lo.Tuple2[Table, Column]]
+	for _, entity := range component {
+		t := mo.TupleToResult(g.Vertex(entity)).MustGet()
+		for _, c := range t.columns {
+			if ref := c.Ref(); ref.IsPresent() {
+				if target := strings.Split(ref.MustGet(), ".")[0]; target != entity && slices.Contains(component, target) {
+					refs = append(refs, fmt.Sprintf("%s.%s -> %s", entity, c.A, target))
+					if first.IsAbsent() {
+						first = mo.Some(lo.T2(t, c))
+					}
+				}
+			}
+		}
+	}
+	t, c := first.MustGet().Unpack()
+	return mo.Some(t.problem(mo.Some(c), "reference cycle: %s", strings.Join(refs, ", ")))
+}
+
+// identifiers validate names of the tables, columns, indexes, foreign keys and native enums against the reserved
+// words and the identifier length limits of the platforms. platforms of a table are the platforms of its datasource,
+// reserved words are accepted when they are quoted
+func (dbo DBO) identifiers(datasources map[string][]string, quote bool) Problems {
+	names := lo.Keys(datasources)
+	slices.Sort(names)
+	all := lo.Uniq(lo.FlatMap(names, func(name string, _ int) []string {
+		return datasources[name]
+	}))
+	var problems Problems
+	enums := map[string]bool{}
+	for _, t := range dbo.Tables() {
+		platforms := lo.Ternary(len(t.datasource) > 0 && len(datasources[t.datasource]) > 0, datasources[t.datasource], all)
+		dialects := lo.FilterMap(platforms, func(platform string, _ int) (Dialect, bool) {
+			return DialectOf(platform).Get()
+		})
+		check := func(c mo.Option[Column], kind, name string, dialects []Dialect) {
+			dbs := func(ds []Dialect) string {
+				return strings.Join(lo.Map(ds, func(d Dialect, _ int) string {
+					return d.Name()
+				}), ", ")
+			}
+			if reserved := lo.Filter(dialects, func(d Dialect, _ int) bool {
+				return d.Reserved(name)
+			}); len(reserved) > 0 && !quote {
+				problems = append(problems, t.problem(c, "%s %s is a reserved word of %s, rename it or enable dbo.quote", kind, name, dbs(reserved)))
+			}
+			if exceeded := lo.Filter(dialects, func(d Dialect, _ int) bool {
+				return d.MaxIdent() > 0 && len(name) > d.MaxIdent()
+			}); len(exceeded) > 0 {
+				limit := lo.MinBy(exceeded, func(a, b Dialect) bool {
+					return a.MaxIdent() < b.MaxIdent()
+				}).MaxIdent()
+				problems = append(problems, t.problem(c, "%s %s is longer than %d characters limit of %s", kind, name, limit, dbs(lo.Filter(exceeded, func(d Dialect, _ int) bool {
+					return d.MaxIdent() == limit
+				}))))
+			}
+		}
+		check(mo.None[Column](), "table", t.Name(), dialects)
+		for _, c := range t.Columns() {
+			check(mo.Some(c), "column", c.Name(), dialects)
+			if e := c.Enum(); e.IsPresent() && !enums[e.MustGet().Name()] {
+				enums[e.MustGet().Name()] = true
+				check(mo.Some(c), "enum", e.MustGet().Name(), lo.Filter(dialects, func(d Dialect, _ int) bool {
+					return e.MustGet().native(d)
+				}))
 			}
 		}
+		for _, idx := range t.Indexes() {
+			check(mo.None[Column](), "index", idx.Name(), dialects)
+		}
+		for _, fk := range dbo.ForeignKeys(t.entity) {
+			check(mo.None[Column](), "foreign key", fk.Name(), dialects)
+		}
 	}
-	return build(dag)
+	return problems
 }
 
 func build(g graph.Graph[string, Table]) mo.Result[DBO] {
+	var problems Problems
+	entities := lo.Keys(mo.TupleToResult(g.PredecessorMap()).MustGet())
+	slices.Sort(entities)
+	// synthesize join tables of many-to-many relationships
+	for _, entity := range entities {
+		t := mo.TupleToResult(g.Vertex(entity)).MustGet()
+		for _, c := range t.columns {
+			if targets := c.Property(colM2M); targets.IsPresent() {
+				if c.Property(colPK).IsAbsent() {
+					problems = append(problems, t.problem(mo.Some(c), "%s.%s: m2m should be declared on primary key", entity, c.A))
+					continue
+				}
+				for _, target := range strings.Split(targets.MustGet(), ",") {
+					target = strings.TrimSpace(target)
+					if rt, err := g.Vertex(target + entity); err == nil && rt.join {
+						problems = append(problems, t.problem(mo.Some(c), "%s: m2m with %s is declared by both entities", entity, target))
+						continue
+					}
+					jt := joinTable(g, t, target)
+					if jt.IsError() {
+						problems = append(problems, t.problem(mo.Some(c), "%s", jt.Error().Error()))
+						continue
+					}
+					if err := g.AddVertex(jt.MustGet()); err != nil {
+						problems = append(problems, t.problem(mo.Some(c), "%s: join table %s with %s conflicts with an existing entity", entity, jt.MustGet().entity, target))
+					}
+				}
+			}
+		}
+	}
+	entities = lo.Keys(mo.TupleToResult(g.PredecessorMap()).MustGet())
+	slices.Sort(entities)
+	// enums are shared by tables, the same name can not be used by different types
+	enums := map[string]Enum{}
+	for _, entity := range entities {
+		t := mo.TupleToResult(g.Vertex(entity)).MustGet()
+		for _, c := range t.columns {
+			if e := c.Enum(); e.IsPresent() {
+				if other, ok := enums[e.MustGet().Name()]; ok && !other.equal(e.MustGet()) {
+					problems = append(problems, t.problem(mo.Some(c), "%s.%s: enum %s conflicts with %s", entity, c.A, e.MustGet().name, other.name))
+					continue
+				}
+				enums[e.MustGet().Name()] = e.MustGet()
+			}
+		}
+	}
 	// build edge
-	for _, entity := range lo.Keys(mo.TupleToResult(g.PredecessorMap()).MustGet()) {
+	for _, entity := range entities {
 		t := mo.TupleToResult(g.Vertex(entity)).MustGet()
+		if pks := t.pkColumns(); pks.IsError() {
+			problems = append(problems, t.problem(mo.None[Column](), "%s", pks.Error().Error()))
+		}
+		names := map[string]Column{}
 		for _, c := range t.columns {
+			if other, ok := names[c.Name()]; ok {
+				problems = append(problems, t.problem(mo.Some(c), "%s.%s: column name %s is used by %s", entity, c.A, c.Name(), other.A))
+			}
+			names[c.Name()] = c
+			if !c.types.mapped(c.GoType()) {
+				problems = append(problems, t.problem(mo.Some(c), "%s.%s: can not find type mapping for %s", entity, c.A, c.AttrType()))
+			} else if err := c.validate(); err != nil {
+				problems = append(problems, t.problem(mo.Some(c), "%s.%s: %s", entity, c.A, err.Error()))
+			}
+		}
+		// an index name can not be used by both `idx` and `uniq`
+		indexes := map[string]ColProperty{}
+		for _, c := range t.columns {
+			for _, p := range []ColProperty{colIdx, colUniq} {
+				for _, name := range c.indexNames(entity, p) {
+					if k, ok := indexes[name]; ok && k != p {
+						problems = append(problems, t.problem(mo.Some(c), "%s: index %s is declared as both %s and %s", entity, name, k, p))
+					}
+					indexes[name] = p
+				}
+			}
+		}
+		for _, c := range t.columns {
+			// check referential actions
+			for _, p := range []ColProperty{colOnDelete, colOnUpdate} {
+				if action := c.Property(p); action.IsPresent() {
+					if c.Ref().IsAbsent() {
+						problems = append(problems, t.problem(mo.Some(c), "%s.%s: %s without ref", entity, c.A, p))
+					} else if !slices.Contains(refActions, strings.ToLower(action.MustGet())) {
+						problems = append(problems, t.problem(mo.Some(c), "%s.%s: invalid %s action %s", entity, c.A, p, action.MustGet()))
+					} else if strings.EqualFold(action.MustGet(), "set null") && !c.Nullable() {
+						problems = append(problems, t.problem(mo.Some(c), "%s.%s: %s set null on not null column", entity, c.A, p))
+					}
+				}
+			}
 			if c.Ref().IsPresent() {
 				referred := strings.Split(c.Ref().MustGet(), ".")
 				// check reference format
 				if len(referred) != 2 {
-					return mo.Err[DBO](fmt.Errorf("invalid column reference: %s", entity))
+					problems = append(problems, t.problem(mo.Some(c), "%s.%s: invalid column reference %s", entity, c.A, c.Ref().MustGet()))
+					continue
 				}
 				// referenced table must be there
 				rt := mo.TupleToResult(g.Vertex(referred[0]))
 				if rt.IsError() {
-					return mo.Err[DBO](fmt.Errorf("%s can not find reference type: %s", entity, referred[0]))
+					problems = append(problems, t.problem(mo.Some(c), "%s.%s: can not find reference type %s", entity, c.A, referred[0]))
+					continue
 				}
 				// check existence of the attribute
 				rc := rt.MustGet().Column(referred[1])
 				if rc.IsAbsent() {
-					return mo.Err[DBO](fmt.Errorf("can not find attribute %s in %s", referred[1], referred[0]))
+					problems = append(problems, t.problem(mo.Some(c), "%s.%s: can not find attribute %s in %s", entity, c.A, referred[1], referred[0]))
+					continue
+				}
+				// referenced table must be shared or in the same datasource
+				if ds := rt.MustGet().datasource; len(ds) > 0 && ds != t.datasource {
+					problems = append(problems, t.problem(mo.Some(c), "%s.%s: can not reference %s of datasource %s", entity, c.A, referred[0], ds))
+					continue
+				}
+				// check type of the attribute, nullable column can reference not null column. enum column must
+				// reference the same enum
+				if c.GoType() != rc.MustGet().GoType() || c.enum.OrEmpty().name != rc.MustGet().enum.OrEmpty().name {
+					problems = append(problems, t.problem(mo.Some(c), "%s.%s: type %s is different from %s of %s", entity, c.A, c.AttrType(), rc.MustGet().AttrType(), c.Ref().MustGet()))
+					continue
+				}
+				if rc.MustGet().Nullable() {
+					problems = append(problems, t.problem(mo.Some(c), "%s.%s: can not reference nullable column %s", entity, c.A, c.Ref().MustGet()))
+					continue
 				}
-				// check type of the attribute
-				rTyp := rc.MustGet()
-				if rTyp.B != c.B && strings.HasSuffix(c.B, rTyp.B) && strings.HasSuffix(rTyp.B, c.B) {
-					return mo.Err[DBO](fmt.Errorf("type of %s.%s and %s is different", entity, c.A, c.Ref().MustGet()))
+				// check precision, the same sql type is required on all the platforms as values are compared in
+				// the sql type. absent precision is the default of the type mapping, e.g. varchar(25)
+				if lo.SomeBy(Dialects(), func(d Dialect) bool {
+					return c.Type(d.Name()) != rc.MustGet().Type(d.Name())
+				}) {
+					problems = append(problems, t.problem(mo.Some(c), "%s.%s: precision %s is different from %s of %s", entity, c.A,
+						c.precisionDef(), rc.MustGet().precisionDef(), c.Ref().MustGet()))
+					continue
 				}
-				// check precision, should do it later
-				//if c.Precision() != rc.MustGet().Precision() {
-				//	return mo.Err[DBX](fmt.Errorf("precision of %s.%s and % is different", entity, c.A, c.Ref().MustGet()))
-				//}
-				g.AddEdge(entity, referred[0], graph.EdgeAttribute("ref", fmt.Sprintf("%s.%s -> %s.%s", t.name, c.A, rt.MustGet().name, rc.MustGet().A)))
+				g.AddEdge(entity, referred[0])
 			}
 		}
 	}
+	if problem, ok := cycle(g).Get(); ok {
+		problems = append(problems, problem)
+	}
+	if len(problems) > 0 {
+		return mo.Err[DBO](problems.sort())
+	}
 	return mo.Ok[DBO](DBO{g: g})
 }
 
-func basicType(typ types.Type) bool {
-	if ts := typ.String(); strings.HasPrefix(ts, "database/sql.Null") || ts == "time.Time" {
+// valueType resolve pointer and sql.Null types to the value type
+func valueType(typ string) GoType {
+	typ = strings.TrimPrefix(typ, "*")
+	if strings.HasPrefix(typ, sqlTypePrefix) {
+		typ = strings.TrimPrefix(typ, sqlTypePrefix)
+		if strings.HasPrefix(typ, "[") {
+			// generic sql.Null[T]
+			typ = typ[1 : len(typ)-1]
+		} else if typ == "Time" {
+			typ = "time.Time"
+		} else {
+			typ = strings.ToLower(typ)
+		}
+	}
+	return GoType(typ)
+}
+
+func basicType(typ types.Type, mappings typeMappings) bool {
+	if ts := typ.String(); strings.HasPrefix(ts, sqlTypePrefix) || mappings.mapped(valueType(ts)) {
 		return true
 	}
 	switch t := typ.(type) {
 	case *types.Basic:
 		return true
 	case *types.Pointer:
-		return basicType(t.Elem())
+		return basicType(t.Elem(), mappings)
+	case *types.Named:
+		_, ok := t.Underlying().(*types.Basic)
+		return ok
 	}
 	return false
 }
 
-func parseColumn(str *types.Struct, inter *types.Interface) mo.Result[[]Column] {
+// enumOf resolve the enum of named basic type, values are the constants of the type declared in its package
+func enumOf(typ types.Type, mappings typeMappings) mo.Option[Enum] {
+	if p, ok := typ.(*types.Pointer); ok {
+		typ = p.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok || mappings.mapped(valueType(named.String())) || named.Obj().Pkg() == nil {
+		return mo.None[Enum]()
+	}
+	basic, ok := named.Underlying().(*types.Basic)
+	if !ok {
+		return mo.None[Enum]()
+	}
+	scope := named.Obj().Pkg().Scope()
+	consts := lo.FilterMap(scope.Names(), func(name string, _ int) (*types.Const, bool) {
+		c, ok := scope.Lookup(name).(*types.Const)
+		return c, ok && types.Identical(c.Type(), named)
+	})
+	slices.SortFunc(consts, func(a, b *types.Const) int {
+		return int(a.Pos() - b.Pos())
+	})
+	return mo.Some(Enum{name: named.Obj().Name(), typ: GoType(basic.Name()), values: lo.Map(consts, func(c *types.Const, _ int) string {
+		if c.Val().Kind() == constant.String {
+			return constant.StringVal(c.Val())
+		}
+		return c.Val().ExactString()
+	})})
+}
+
+// docComments collect doc comments of types and struct fields by the position of their names,
+// trailing line comment of the field is used when it has no doc comment
+func docComments(pkgs []*packages.Package) map[token.Pos]string {
+	docs := map[token.Pos]string{}
+	text := func(groups ...*ast.CommentGroup) string {
+		for _, group := range groups {
+			if group != nil {
+				return strings.Join(strings.Fields(group.Text()), " ")
+			}
+		}
+		return ""
+	}
+	for _, pkg := range pkgs {
+		for _, syntax := range pkg.Syntax {
+			ast.Inspect(syntax, func(node ast.Node) bool {
+				switch n := node.(type) {
+				case *ast.GenDecl:
+					for _, spec := range n.Specs {
+						if ts, ok := spec.(*ast.TypeSpec); ok {
+							docs[ts.Name.Pos()] = text(ts.Doc, lo.If(len(n.Specs) == 1, n.Doc).Else(nil))
+						}
+					}
+				case *ast.Field:
+					for _, name := range n.Names {
+						docs[name.Pos()] = text(n.Doc, n.Comment)
+					}
+				}
+				return true
+			})
+		}
+	}
+	return docs
+}
+
+// datasource return the datasource declared by directive `//dbo:datasource <name>` in the comments,
+// datasource names are case-insensitive as the configuration keys
+func datasource(group *ast.CommentGroup) string {
+	if group == nil {
+		return ""
+	}
+	for _, comment := range group.List {
+		if name, ok := strings.CutPrefix(comment.Text, datasourceDirective); ok {
+			return strings.ToLower(strings.TrimSpace(name))
+		}
+	}
+	return ""
+}
+
+// directives return datasources declared on the type declarations
+func directives(pkgs []*packages.Package) map[token.Pos]string {
+	datasources := map[token.Pos]string{}
+	for _, pkg := range pkgs {
+		for _, syntax := range pkg.Syntax {
+			ast.Inspect(syntax, func(node ast.Node) bool {
+				if decl, ok := node.(*ast.GenDecl); ok {
+					for _, spec := range decl.Specs {
+						if ts, ok := spec.(*ast.TypeSpec); ok {
+							datasources[ts.Name.Pos()] = lo.CoalesceOrEmpty(datasource(ts.Doc), datasource(lo.If(len(decl.Specs) == 1, decl.Doc).Else(nil)))
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	return datasources
+}
+
+// parseColumn parse columns of the struct. when naming strategy is present, untagged basic fields are columns
+// and the column name is derived from the field name when `col` is absent. field tagged with `db:"-"` is ignored.
+// it goes on parsing the rest fields when a field is invalid, and reports all the problems at the position of the field.
+// mappings are the custom type mappings of the project
+func parseColumn(pkg *packages.Package, str *types.Struct, inter *types.Interface, docs map[token.Pos]string, naming mo.Option[Naming], mappings typeMappings) ([]Column, Problems) {
 	// 1: can not have no-builtin type, if it has, it must be embedded
 	var columns []Column
+	var problems Problems
 	for i := range str.NumFields() {
 		if f := str.Field(i); f.Exported() && f.IsField() {
+			var tag string
+			if matched := dbReg.FindStringSubmatch(str.Tag(i)); len(matched) > 1 {
+				tag = strings.TrimSpace(matched[1])
+			}
+			if tag == "-" {
+				continue
+			}
 			if implements(f.Type(), inter) {
-				return mo.Err[[]Column](fmt.Errorf("%s is entity type", f.Name()))
+				problems = append(problems, Problem{Position: position(pkg, f.Pos()), Message: fmt.Sprintf("%s is entity type", f.Name())})
+				continue
 			}
-			if !basicType(f.Type()) {
+			if !basicType(f.Type(), mappings) {
 				if !f.Embedded() {
-					fmt.Printf("%s is a basic type %s\n", f.Name(), f.Type().String())
-					return mo.Err[[]Column](fmt.Errorf("%s is not a basic type", f.Name()))
-				} else {
-					if cStr, ok := f.Type().Underlying().(*types.Struct); ok {
-						if child := parseColumn(cStr, inter); child.IsOk() {
-							columns = append(columns, child.MustGet()...)
-						} else {
-							return mo.Err[[]Column](child.Error())
-						}
-					}
+					problems = append(problems, Problem{Position: position(pkg, f.Pos()), Message: fmt.Sprintf("%s is not a basic type", f.Name())})
+				} else if cStr, ok := f.Type().Underlying().(*types.Struct); ok {
+					child, childProblems := parseColumn(pkg, cStr, inter, docs, naming, mappings)
+					columns = append(columns, child...)
+					problems = append(problems, childProblems...)
 				}
 			} else {
-				if matched := dbReg.FindStringSubmatch(str.Tag(i)); len(matched) > 0 {
-					c := Column{A: f.Name(), B: f.Type().String(), C: matched[1]}
+				if dbReg.MatchString(str.Tag(i)) || naming.IsPresent() {
+					c := Column{A: f.Name(), B: f.Type().String(), C: tag, enum: enumOf(f.Type(), mappings), doc: docs[f.Pos()], pos: f.Pos(), types: mappings}
+					if c.Property(colName).IsAbsent() && naming.IsPresent() {
+						c.C = strings.Join(lo.Compact([]string{fmt.Sprintf("%s=%s", colName, naming.MustGet().Column(f.Name())), tag}), ";")
+					}
 					if c.Property(colName).IsAbsent() {
-						return mo.Err[[]Column](fmt.Errorf("no column definition for %s", f.Name()))
+						problems = append(problems, Problem{Position: position(pkg, f.Pos()), Message: fmt.Sprintf("no column definition for %s", f.Name())})
+						continue
 					}
 					columns = append(columns, c)
 				}
 			}
 		}
 	}
-	return lo.If(len(columns) > 0, mo.Ok[[]Column](columns)).Else(mo.Err[[]Column](fmt.Errorf("no columns found")))
+	return columns, problems
 }
 
 // implements Function to check if a type implements an interface
@@ -465,12 +1801,11 @@ func implements(t types.Type, inter *types.Interface) bool {
 	return types.Implements(t, inter) || types.Implements(types.NewPointer(t), inter)
 }
 
-// Helper function to convert AST expression to string
-func exprToString(expr ast.Expr) string {
-	var sb strings.Builder
-	err := format.Node(&sb, token.NewFileSet(), expr)
-	if err != nil {
-		log.Fatalf("Could not format expression: %v", err)
+// tableName evaluate the table name returned by `Table()`, it must be a string constant expression
+func tableName(pkg *packages.Package, expr ast.Expr) mo.Result[string] {
+	tv, ok := pkg.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return mo.Err[string](Problem{Position: position(pkg, expr.Pos()), Message: fmt.Sprintf("table name %s can not be resolved at compile time", types.ExprString(expr))})
 	}
-	return sb.String()
+	return mo.Ok(strings.TrimSpace(constant.StringVal(tv.Value)))
 }
@@ -1,10 +1,21 @@
 package meta
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/dominikbraun/graph"
 	"github.com/kcmvp/app"
+	"github.com/samber/lo"
+	"github.com/samber/mo"
 	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"golang.org/x/tools/go/packages"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -29,3 +40,865 @@ func TestDBO_Columns(t *testing.T) {
 	er := result.MustGet()
 	er.Columns(filepath.Join(app.RootDir(), "target"))
 }
+
+func TestDBO_ColumnsQuote(t *testing.T) {
+	dbo := newDBO(Table{entity: "Order", name: "order", pkg: &packages.Package{PkgPath: "example.com/shop", Name: "shop"}, columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Group", B: "string", C: "col=group(10)"},
+	}}).MustGet()
+	tests := []struct {
+		name    string
+		dialect mo.Option[Dialect]
+		column  string
+	}{
+		{name: "raw", dialect: mo.None[Dialect](), column: `B: "group"`},
+		{name: "mysql", dialect: DialectOf("mysql"), column: "B: \"`group`\""},
+		{name: "pg", dialect: DialectOf("pg"), column: `B: "\"group\""`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			assert.NoError(t, dbo.columns(dir, test.dialect))
+			data, err := os.ReadFile(filepath.Join(dir, "columns", "order", "order_columns.go"))
+			assert.NoError(t, err)
+			assert.Contains(t, string(data), test.column)
+			assert.Contains(t, string(data), `B: "id"`)
+		})
+	}
+}
+
+func TestTable_Indexes(t *testing.T) {
+	table := Table{entity: "OrderItem", name: "order_item", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "OrderId", B: "int64", C: "col=order_id;idx"},
+		{A: "ProductId", B: "int64", C: "col=product_id;uniq=uk_order_product"},
+		{A: "Sku", B: "string", C: "col=sku(20);idx=idx_sku_batch,idx_sku"},
+		{A: "Batch", B: "string", C: "col=batch(10);idx=idx_sku_batch;uniq=uk_order_product"},
+	}}
+	indexes := table.Indexes()
+	assert.Len(t, indexes, 4)
+	assert.Equal(t, []Index{
+		{name: "idx_order_item_order_id", columns: []string{"order_id"}},
+		{name: "uk_order_product", unique: true, columns: []string{"product_id", "batch"}},
+		{name: "idx_sku_batch", columns: []string{"sku", "batch"}},
+		{name: "idx_sku", columns: []string{"sku"}},
+	}, indexes)
+	assert.Equal(t, "product_id, batch", indexes[1].Columns())
+}
+
+func newDBO(tables ...Table) mo.Result[DBO] {
+	g := graph.New[string, Table](func(table Table) string {
+		return table.entity
+	}, graph.Directed())
+	for _, table := range tables {
+		g.AddVertex(table)
+	}
+	return build(g)
+}
+
+func TestDBO_ForeignKeys(t *testing.T) {
+	dbo := newDBO(
+		Table{entity: "OrderItem", name: "order_item", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "OrderId", B: "int64", C: "col=order_id;ref=Order.Id;onDelete=cascade"},
+			{A: "ProductId", B: "*int64", C: "col=product_id;ref=Product.Id;onDelete=set null;onUpdate=no action"},
+		}},
+		Table{entity: "Order", name: "orders", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "ParentId", B: "*int64", C: "col=parent_id;ref=Order.Id"},
+		}},
+		Table{entity: "Product", name: "product", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+		}},
+	).MustGet()
+	fks := dbo.ForeignKeys("OrderItem")
+	assert.Len(t, fks, 2)
+	assert.Equal(t, "fk_order_item_order_id", fks[0].Name())
+	assert.Equal(t, "orders", fks[0].RefTable())
+	assert.Equal(t, "id", fks[0].RefColumn())
+	assert.Equal(t, " ON DELETE CASCADE", fks[0].Actions())
+	assert.Equal(t, " ON DELETE SET NULL ON UPDATE NO ACTION", fks[1].Actions())
+	assert.Equal(t, []string{"Order", "Product", "OrderItem"}, lo.Map(dbo.Tables(), func(t Table, _ int) string {
+		return t.Entity()
+	}))
+}
+
+func TestDBO_ForeignKeysInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		typ  string
+	}{
+		{"without ref", "col=product_id;onDelete=cascade", "int64"},
+		{"invalid action", "col=product_id;ref=Product.Id;onDelete=drop", "int64"},
+		{"set null on not null", "col=product_id;ref=Product.Id;onDelete=set null", "int64"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dbo := newDBO(
+				Table{entity: "OrderItem", name: "order_item", columns: []Column{
+					{A: "Id", B: "int64", C: "col=id;pk"},
+					{A: "ProductId", B: test.typ, C: test.tag},
+				}},
+				Table{entity: "Product", name: "product", columns: []Column{
+					{A: "Id", B: "int64", C: "col=id;pk"},
+				}},
+			)
+			assert.True(t, dbo.IsError())
+		})
+	}
+}
+
+func TestDBO_Relations(t *testing.T) {
+	sales := &packages.Package{Name: "entity", PkgPath: "example.com/sales/entity"}
+	crm := &packages.Package{Name: "entity", PkgPath: "example.com/crm/entity"}
+	dbo := newDBO(
+		Table{entity: "Customer", name: "customer", pkg: crm, columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+		}},
+		Table{entity: "Profile", name: "profile", pkg: crm, columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "CustomerId", B: "int64", C: "col=customer_id;uniq;ref=Customer.Id"},
+		}},
+		Table{entity: "Order", name: "orders", pkg: sales, columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "CustomerId", B: "int64", C: "col=customer_id;ref=Customer.Id"},
+			{A: "ReferrerId", B: "*int64", C: "col=referrer_id;ref=Customer.Id"},
+		}},
+	).MustGet()
+	assert.Equal(t, []string{"entity", "example_com_sales_entity"}, lo.Map(dbo.Clusters(), func(c Cluster, _ int) string {
+		return c.Name()
+	}))
+	relations := dbo.Relations()
+	assert.Equal(t, []string{
+		"example_com_sales_entity.orders.CustomerId -> entity.customer.Id: many-to-one",
+		"example_com_sales_entity.orders.ReferrerId -> entity.customer.Id: optional many-to-one",
+		"entity.profile.CustomerId -> entity.customer.Id: one-to-one",
+	}, lo.Map(relations, func(r Relation, _ int) string {
+		return fmt.Sprintf("%s -> %s: %s", r.Source(), r.Target(), r.Label())
+	}))
+	assert.Equal(t, "cf-one-required", relations[0].TargetArrowhead())
+	assert.Equal(t, "cf-many", relations[1].SourceArrowhead())
+	assert.Equal(t, "cf-one", relations[1].TargetArrowhead())
+	assert.Equal(t, "cf-one", relations[2].SourceArrowhead())
+}
+
+func TestDBO_ERFormats(t *testing.T) {
+	dbo := newDBO(
+		Table{entity: "Customer", name: "customer", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Name", B: "string", C: "col=name", doc: `customer's "full" name`},
+		}},
+		Table{entity: "Order", name: "orders", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "CustomerId", B: "*int64", C: "col=customer_id;ref=Customer.Id"},
+			{A: "CreatedAt", B: "time.Time", C: "col=created_at"},
+		}},
+	).MustGet()
+	dir := t.TempDir()
+	assert.NoError(t, dbo.ER(dir, ERFormats()...))
+	read := func(name string) string {
+		return string(lo.Must(os.ReadFile(filepath.Join(dir, name))))
+	}
+	mermaid := read("er.mmd")
+	assert.Contains(t, mermaid, "        string Name \"customer's 'full' name\"\n")
+	assert.Contains(t, mermaid, "        time_Time CreatedAt\n")
+	assert.Contains(t, mermaid, "        int64 CustomerId FK\n")
+	assert.Contains(t, mermaid, "    orders }o--o| customer : CustomerId\n")
+	plantuml := read("er.puml")
+	assert.Contains(t, plantuml, "    * Id : int64 <<PK>>\n")
+	assert.Contains(t, plantuml, "    CustomerId : *int64 <<FK>>\n")
+	assert.Contains(t, plantuml, "orders }o--o| customer : CustomerId\n")
+	dot := read("er.dot")
+	assert.Contains(t, dot, `<tr><td port="Id" align="left">Id (PK)</td><td align="left">int64</td></tr>`)
+	assert.Contains(t, dot, `    "orders" [label=<<table border="0" cellborder="1" cellspacing="0">`)
+	assert.Contains(t, dot, `"orders":"CustomerId" -> "customer":"Id" [arrowtail=crowodot, arrowhead=teeodot];`)
+	assert.Contains(t, read("er.d2"), "orders.CustomerId -> customer.Id: optional many-to-one")
+	assert.Error(t, dbo.ER(dir, "svg"))
+}
+
+func TestBuildCycle(t *testing.T) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("entity.go", -1, 100)
+	file.SetLines([]int{0, 20, 40, 60, 80})
+	pkg := &packages.Package{Fset: fset}
+	dbo := newDBO(
+		Table{entity: "Customer", name: "customer", pkg: pkg, columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "AddressId", B: "*int64", C: "col=address_id;ref=Address.Id", pos: file.Pos(21)},
+		}},
+		Table{entity: "Address", name: "address", pkg: pkg, columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "RegionId", B: "int64", C: "col=region_id;ref=Region.Id", pos: file.Pos(41)},
+		}},
+		Table{entity: "Region", name: "region", pkg: pkg, columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "ParentId", B: "*int64", C: "col=parent_id;ref=Region.Id"},
+			{A: "ManagerId", B: "*int64", C: "col=manager_id;ref=Customer.Id", pos: file.Pos(61)},
+		}},
+	)
+	assert.EqualError(t, dbo.Error(), "entity.go:3:2: reference cycle: Address.RegionId -> Region, Customer.AddressId -> Address, Region.ManagerId -> Customer")
+	var problems Problems
+	assert.ErrorAs(t, dbo.Error(), &problems)
+	assert.Equal(t, "Address", problems[0].Entity)
+}
+
+func TestDBO_Drop(t *testing.T) {
+	status := Enum{name: "OrderStatus", typ: "string", values: []string{"created", "paid"}}
+	dbo := newDBO(
+		Table{entity: "Customer", name: "customer", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+		}},
+		Table{entity: "Order", name: "orders", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "CustomerId", B: "int64", C: "col=customer_id;ref=Customer.Id"},
+			{A: "Status", B: "entity.OrderStatus", C: "col=status", enum: mo.Some(status)},
+			{A: "UpdatedAt", B: "time.Time", C: "col=updated_at;aut"},
+		}},
+	).MustGet()
+	assert.Equal(t, []string{
+		"drop trigger trg_order_updated_at on orders;",
+		"drop function trg_order_updated_at();",
+		"drop table orders;",
+		"drop table customer;",
+		"drop type order_status;",
+	}, dbo.drop(DialectOf("pg").MustGet()))
+	assert.Equal(t, []string{"drop table orders;", "drop table customer;"}, dbo.drop(DialectOf("mysql").MustGet()))
+}
+
+func TestManyToMany(t *testing.T) {
+	dbo := newDBO(
+		Table{entity: "Post", name: "post", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk;m2m=Tag, Post"},
+		}},
+		Table{entity: "Tag", name: "tag", columns: []Column{
+			{A: "Name", B: "string", C: "col=name(20);pk"},
+		}},
+	).MustGet()
+	join := dbo.Table("PostTag")
+	assert.True(t, join.Join())
+	assert.Equal(t, "post_tag", join.Name())
+	assert.Equal(t, []Column{
+		{A: "PostId", B: "int64", C: "col=post_id;pk=1;ref=Post.Id;onDelete=cascade"},
+		{A: "TagName", B: "string", C: "col=tag_name(20);pk=2;ref=Tag.Name;onDelete=cascade"},
+	}, join.Columns())
+	assert.Equal(t, "post_id, tag_name", join.PK())
+	assert.Len(t, dbo.ForeignKeys("PostTag"), 2)
+	assert.Equal(t, []string{"post_id", "related_post_id"}, lo.Map(dbo.Table("PostPost").Columns(), func(c Column, _ int) string {
+		return c.Name()
+	}))
+	var sb strings.Builder
+	assert.NoError(t, dbo.schema("pg").MustGet().Execute(&sb, dbo))
+	assert.Contains(t, sb.String(), "create table post_tag\n(\n    post_id  bigint not null,\n    tag_name varchar(20) not null,")
+	assert.Empty(t, lo.Filter(dbo.snapshot(1).Tables, func(t tableSnapshot, _ int) bool {
+		return t.Entity == "PostTag"
+	}))
+}
+
+func TestManyToManyInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		post string
+		tag  string
+		code string
+	}{
+		{"unknown target", "col=id;pk;m2m=Label", "col=id;pk", "col=code"},
+		{"not on primary key", "col=id;pk", "col=id;pk", "col=code;m2m=Post"},
+		{"composite primary key", "col=id;pk;m2m=Tag", "col=id;pk=1", "col=code;pk=2"},
+		{"both sides", "col=id;pk;m2m=Tag", "col=id;pk;m2m=Post", "col=code"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dbo := newDBO(
+				Table{entity: "Post", name: "post", columns: []Column{
+					{A: "Id", B: "int64", C: test.post},
+					{A: "Title", B: "string", C: "col=title"},
+				}},
+				Table{entity: "Tag", name: "tag", columns: []Column{
+					{A: "Id", B: "int64", C: test.tag},
+					{A: "Code", B: "string", C: test.code},
+				}},
+			)
+			assert.True(t, dbo.IsError())
+		})
+	}
+}
+
+func TestTable_PK(t *testing.T) {
+	tests := []struct {
+		name     string
+		columns  []Column
+		pk       string
+		identity bool
+		err      bool
+	}{
+		{"single", []Column{{A: "Id", B: "int64", C: "col=id;pk"}, {A: "Name", B: "string", C: "col=name"}}, "id", true, false},
+		{"composite", []Column{{A: "TagId", B: "int64", C: "col=tag_id;pk"}, {A: "PostId", B: "int64", C: "col=post_id;pk"}}, "tag_id, post_id", false, false},
+		{"ordinal", []Column{{A: "TagId", B: "int64", C: "col=tag_id;pk=2"}, {A: "PostId", B: "int64", C: "col=post_id;pk=1"}}, "post_id, tag_id", false, false},
+		{"reference", []Column{{A: "Id", B: "int64", C: "col=id;pk;ref=User.Id"}}, "id", false, false},
+		{"missing", []Column{{A: "Id", B: "int64", C: "col=id"}}, "", false, true},
+		{"partial ordinal", []Column{{A: "TagId", B: "int64", C: "col=tag_id;pk=1"}, {A: "PostId", B: "int64", C: "col=post_id;pk"}}, "", false, true},
+		{"duplicated ordinal", []Column{{A: "TagId", B: "int64", C: "col=tag_id;pk=1"}, {A: "PostId", B: "int64", C: "col=post_id;pk=1"}}, "", false, true},
+		{"invalid ordinal", []Column{{A: "TagId", B: "int64", C: "col=tag_id;pk=a"}}, "", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			table := Table{entity: "PostTag", name: "post_tag", columns: test.columns}
+			assert.Equal(t, test.err, table.pkColumns().IsError())
+			assert.Equal(t, test.pk, table.PK())
+			assert.Equal(t, test.identity, table.Identity(test.columns[0]))
+		})
+	}
+}
+
+func TestColumn_GoType(t *testing.T) {
+	tests := []struct {
+		typ    string
+		goType GoType
+		pg     string
+	}{
+		{"int64", "int64", "bigint"},
+		{"*int32", "int32", "integer"},
+		{"database/sql.NullString", "string", "varchar(25)"},
+		{"database/sql.NullTime", "time.Time", "timestamp"},
+		{"database/sql.Null[int16]", "int16", "smallint"},
+		{"database/sql.Null[[]byte]", "[]byte", "bytea"},
+		{"*github.com/google/uuid.UUID", "github.com/google/uuid.UUID", "uuid"},
+		{"encoding/json.RawMessage", "encoding/json.RawMessage", "jsonb"},
+		{"github.com/shopspring/decimal.Decimal", "github.com/shopspring/decimal.Decimal", "decimal(38, 2)"},
+		{"time.Duration", "time.Duration", "bigint"},
+	}
+	for _, test := range tests {
+		t.Run(test.typ, func(t *testing.T) {
+			c := Column{A: "Attr", B: test.typ, C: "col=attr"}
+			assert.Equal(t, test.goType, c.GoType())
+			assert.Equal(t, test.pg, c.Type("pg"))
+		})
+	}
+	// custom type mappings take precedence over the builtin ones
+	c := Column{A: "Id", B: "github.com/google/uuid.UUID", C: "col=id", types: typeMappings{"github.com/google/uuid.UUID": {"pg": "varchar(36)"}}}
+	assert.Equal(t, "varchar(36)", c.Type("pg"))
+	assert.Equal(t, "char(36)", c.Type("mysql"))
+}
+
+func TestBuildUnmappedType(t *testing.T) {
+	dbo := newDBO(Table{entity: "Customer", name: "customer", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Score", B: "complex128", C: "col=score"},
+	}})
+	assert.True(t, dbo.IsError())
+	assert.Equal(t, "Customer.Score: can not find type mapping for complex128", dbo.Error().Error())
+}
+
+func TestEnumOf(t *testing.T) {
+	src := `package entity
+
+type Status string
+
+const (
+	Paid    Status = "paid"
+	Created Status = "created"
+	Other          = "other"
+)
+
+type Level int8
+
+const (
+	Low Level = iota + 1
+	High
+)
+
+type Code string
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "entity.go", src, 0)
+	assert.NoError(t, err)
+	pkg, err := (&types.Config{}).Check("entity", fset, []*ast.File{file}, nil)
+	assert.NoError(t, err)
+	status := enumOf(pkg.Scope().Lookup("Status").Type(), nil)
+	assert.Equal(t, "status", status.MustGet().Name())
+	assert.Equal(t, []string{"paid", "created"}, status.MustGet().Values())
+	assert.Equal(t, "'paid', 'created'", status.MustGet().Literals())
+	level := enumOf(types.NewPointer(pkg.Scope().Lookup("Level").Type()), nil)
+	assert.Equal(t, GoType("int8"), level.MustGet().Type())
+	assert.Equal(t, "1, 2", level.MustGet().Literals())
+	assert.True(t, basicType(pkg.Scope().Lookup("Code").Type(), nil))
+	assert.Empty(t, enumOf(pkg.Scope().Lookup("Code").Type(), nil).MustGet().Values())
+	assert.True(t, enumOf(types.Typ[types.String], nil).IsAbsent())
+}
+
+func TestColumn_Enum(t *testing.T) {
+	status := Enum{name: "OrderStatus", typ: "string", values: []string{"new", "paid"}}
+	level := Enum{name: "Level", typ: "int8", values: []string{"1", "2"}}
+	c := Column{A: "Status", B: "entity.OrderStatus", C: "col=status(10)", enum: mo.Some(status)}
+	assert.Equal(t, "order_status not null", c.Def("pg"))
+	assert.Equal(t, "varchar(10) not null check (status in ('new', 'paid'))", c.Def("mysql"))
+	assert.Equal(t, "text(10) not null check (status in ('new', 'paid'))", c.Def("sqlite"))
+	c = Column{A: "Level", B: "*entity.Level", C: "col=level", enum: mo.Some(level)}
+	assert.Equal(t, "int8 check (level in (1, 2))", c.Def("pg"))
+	c = Column{A: "Code", B: "entity.Code", C: "col=code", enum: mo.Some(Enum{name: "Code", typ: "string"})}
+	assert.True(t, c.Enum().IsAbsent())
+	assert.Equal(t, "varchar(25) not null", c.Def("pg"))
+}
+
+func TestBuildEnumConflict(t *testing.T) {
+	dbo := newDBO(
+		Table{entity: "Customer", name: "customer", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Status", B: "a.Status", C: "col=status", enum: mo.Some(Enum{name: "Status", typ: "string", values: []string{"new"}})},
+		}},
+		Table{entity: "Order", name: "orders", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Status", B: "b.Status", C: "col=status", enum: mo.Some(Enum{name: "Status", typ: "string", values: []string{"paid"}})},
+		}},
+	)
+	assert.True(t, dbo.IsError())
+	assert.Contains(t, dbo.Error().Error(), "enum Status conflicts with Status")
+}
+
+func TestColumn_DefaultCheck(t *testing.T) {
+	c := Column{A: "Price", B: "float64", C: "col=price(10,2);default=0;check=price >= 0"}
+	assert.Equal(t, "price >= 0", c.Property(colCheck).MustGet())
+	assert.Equal(t, "decimal(10, 2) not null default 0 check (price >= 0)", c.Def("pg"))
+	c = Column{A: "Active", B: "bool", C: "col=active;default=TRUE"}
+	assert.Equal(t, "boolean not null default true", c.Def("pg"))
+	assert.Equal(t, "integer not null default 1", c.Def("sqlite"))
+	assert.Equal(t, "bit not null default 1", c.Def("sqlserver"))
+	c = Column{A: "Name", B: "*string", C: "col=name(20);default=it's"}
+	assert.Equal(t, "varchar(20) default 'it''s'", c.Def("mysql"))
+	c = Column{A: "CreatedAt", B: "time.Time", C: "col=created_at;default=now"}
+	assert.Equal(t, "timestamp not null default current_timestamp", c.Def("pg"))
+	assert.Equal(t, `[PK; "default now"]`, Column{A: "Id", B: "int64", C: "col=id;pk;default=now"}.Constraint().MustGet())
+}
+
+func TestColumn_Validate(t *testing.T) {
+	status := mo.Some(Enum{name: "Status", typ: "string", values: []string{"new", "paid"}})
+	tests := []struct {
+		column Column
+		err    string
+	}{
+		{Column{A: "Age", B: "int32", C: "col=age;default=18;check=age > 0"}, ""},
+		{Column{A: "Age", B: "*uint8", C: "col=age;default=-1"}, "invalid default value -1"},
+		{Column{A: "Age", B: "int32", C: "col=age;default=abc"}, "invalid default value abc"},
+		{Column{A: "Age", B: "int32", C: "col=age;default"}, "default value is required"},
+		{Column{A: "Rate", B: "float64", C: "col=rate;default=0.5"}, ""},
+		{Column{A: "Active", B: "bool", C: "col=active;default=yes"}, "invalid default value yes"},
+		{Column{A: "At", B: "time.Time", C: "col=at;default=2024-01-01"}, ""},
+		{Column{A: "At", B: "time.Time", C: "col=at;default=tomorrow"}, "invalid time tomorrow"},
+		{Column{A: "Data", B: "[]byte", C: "col=data;default=abc"}, "default value is not supported for []byte"},
+		{Column{A: "Status", B: "entity.Status", C: "col=status;default=paid", enum: status}, ""},
+		{Column{A: "Status", B: "entity.Status", C: "col=status;default=done", enum: status}, "is not one of new, paid"},
+		{Column{A: "Age", B: "int32", C: "col=age;check=(age > 0"}, "unbalanced parentheses"},
+		{Column{A: "Age", B: "int32", C: "col=age;check=price > 0"}, "does not reference column age"},
+		{Column{A: "Price", B: "float64", C: "col=price(10, 2)"}, ""},
+		{Column{A: "Active", B: "bool", C: "col=active(10)"}, "precision (10) is not supported by bool"},
+		{Column{A: "Name", B: "string", C: "col=name(10,2)"}, "precision (10,2) has more than 1 values for string"},
+		{Column{A: "Name", B: "string", C: "col=name(abc)"}, "invalid precision (abc)"},
+		{Column{A: "Price", B: "float64", C: "col=price(5,10)"}, "scale 10 is greater than precision 5"},
+	}
+	for _, test := range tests {
+		t.Run(test.column.C, func(t *testing.T) {
+			err := test.column.validate()
+			if len(test.err) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestColumn_Managed(t *testing.T) {
+	table := Table{entity: "Customer", name: "customer", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "CreatedAt", B: "time.Time", C: "col=created_at;act"},
+		{A: "UpdatedAt", B: "time.Time", C: "col=updated_at;aut"},
+	}}
+	created, updated := table.columns[1], table.columns[2]
+	assert.Equal(t, []Column{created, updated}, table.Managed())
+	assert.Equal(t, "timestamp not null default current_timestamp", created.Def("pg"))
+	assert.Equal(t, "timestamp not null default current_timestamp", created.Def("mysql"))
+	assert.Equal(t, "timestamp not null default current_timestamp on update current_timestamp", updated.Def("mysql"))
+	assert.Equal(t, "timestamp not null default current_timestamp", updated.Def("pg"))
+	assert.Empty(t, table.Triggers("mysql"))
+	pg := table.Triggers("pg")
+	assert.Len(t, pg, 2)
+	assert.Contains(t, pg[0], "create or replace function trg_customer_updated_at() returns trigger")
+	assert.Equal(t, "create trigger trg_customer_updated_at before update on customer for each row execute function trg_customer_updated_at();", pg[1])
+	assert.Equal(t, []string{"create trigger trg_customer_updated_at after update on customer for each row when new.updated_at = old.updated_at\nbegin\n    update customer set updated_at = current_timestamp where id = new.id;\nend;"}, table.Triggers("sqlite"))
+	assert.ErrorContains(t, Column{A: "At", B: "string", C: "col=at;act"}.validate(), "act is only supported by time.Time")
+	assert.ErrorContains(t, Column{A: "At", B: "time.Time", C: "col=at;aut;default=now"}.validate(), "aut can not be used with default")
+	assert.ErrorContains(t, Column{A: "At", B: "time.Time", C: "col=at;aut;act"}.validate(), "act can not be used with aut")
+}
+
+func TestComments(t *testing.T) {
+	table := Table{entity: "Customer", name: "customer", doc: "customer of the shop", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Name", B: "string", C: "col=name(20)", doc: "customer's name"},
+	}}
+	dbo := newDBO(table).MustGet()
+	schema := func(db string) string {
+		var sb strings.Builder
+		assert.NoError(t, dbo.schema(db).MustGet().Execute(&sb, dbo))
+		return sb.String()
+	}
+	pg := schema("pg")
+	assert.Contains(t, pg, "comment on table customer is 'customer of the shop';")
+	assert.Contains(t, pg, "comment on column customer.name is 'customer''s name';")
+	mysql := schema("mysql")
+	assert.Contains(t, mysql, "name varchar(20) not null comment 'customer''s name',")
+	assert.Contains(t, mysql, ") comment 'customer of the shop';")
+	assert.NotContains(t, mysql, "comment on")
+	sqlite := schema("sqlite")
+	assert.True(t, strings.HasPrefix(sqlite, "-- customer of the shop\ncreate table customer"))
+	assert.Contains(t, sqlite, "name text(20) not null, -- customer's name")
+}
+
+func TestNaming(t *testing.T) {
+	snake := Naming{Strategy: "snake", Prefix: "t_"}
+	assert.Equal(t, "customer_id", snake.Column("CustomerID"))
+	assert.Equal(t, "t_order_item", snake.Table("OrderItem"))
+	camel := Naming{Strategy: "lowerCamel"}
+	assert.Equal(t, "customerId", camel.Column("CustomerId"))
+	assert.Equal(t, "orderItem", camel.Table("OrderItem"))
+	assert.True(t, loadConfig(filepath.Join("testdata", "config"), "").naming.MustGet().IsAbsent())
+	naming := loadConfig(filepath.Join("testdata", "naming"), "").naming.MustGet()
+	assert.Equal(t, Naming{Strategy: "lowerCamel", Prefix: "t_"}, naming.MustGet())
+	assert.Equal(t, "t_orderItem", naming.MustGet().Table("OrderItem"))
+}
+
+func TestParseColumnNaming(t *testing.T) {
+	src := `package entity
+
+type Customer struct {
+	Id      int64  ` + "`db:\"col=id;pk\"`" + `
+	Name    string ` + "`db:\"uniq\"`" + `
+	NickName string
+	Secret  string ` + "`db:\"-\"`" + `
+	Orders  []string ` + "`db:\"-\"`" + `
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "entity.go", src, 0)
+	assert.NoError(t, err)
+	pkg, err := (&types.Config{}).Check("entity", fset, []*ast.File{file}, nil)
+	assert.NoError(t, err)
+	str := pkg.Scope().Lookup("Customer").Type().Underlying().(*types.Struct)
+	table := types.NewFunc(token.NoPos, nil, "Table", types.NewSignatureType(nil, nil, nil, nil, types.NewTuple(types.NewVar(token.NoPos, nil, "", types.Typ[types.String])), false))
+	inter := types.NewInterfaceType([]*types.Func{table}, nil).Complete()
+	source := &packages.Package{Fset: fset}
+	columns, problems := parseColumn(source, str, inter, map[token.Pos]string{}, mo.Some(Naming{Strategy: "snake"}), nil)
+	assert.Empty(t, problems)
+	assert.Equal(t, []string{"col=id;pk", "col=name;uniq", "col=nick_name"}, lo.Map(columns, func(c Column, _ int) string {
+		return c.C
+	}))
+	columns, problems = parseColumn(source, str, inter, map[token.Pos]string{}, mo.None[Naming](), nil)
+	assert.Len(t, columns, 1)
+	assert.EqualError(t, problems, "entity.go:5:2: no column definition for Name")
+}
+
+func TestTableName(t *testing.T) {
+	src := `package entity
+
+const prefix = "app_"
+
+func name() string { return "name" }
+
+var (
+	literal  = "customer"
+	constant = prefix + "order"
+	empty    = ""
+	call     = name()
+)
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "entity.go", src, 0)
+	assert.NoError(t, err)
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}}
+	_, err = (&types.Config{}).Check("entity", fset, []*ast.File{file}, info)
+	assert.NoError(t, err)
+	pkg := &packages.Package{Fset: fset, TypesInfo: info}
+	values := map[string]ast.Expr{}
+	for _, spec := range file.Decls[2].(*ast.GenDecl).Specs {
+		values[spec.(*ast.ValueSpec).Names[0].Name] = spec.(*ast.ValueSpec).Values[0]
+	}
+	assert.Equal(t, "customer", tableName(pkg, values["literal"]).MustGet())
+	assert.Equal(t, "app_order", tableName(pkg, values["constant"]).MustGet())
+	assert.Empty(t, tableName(pkg, values["empty"]).MustGet())
+	assert.EqualError(t, tableName(pkg, values["call"]).Error(), "entity.go:11:13: table name name() can not be resolved at compile time")
+}
+
+func TestDocComments(t *testing.T) {
+	src := `package entity
+
+// Customer customer of the shop
+type Customer struct {
+	// Name full name
+	// of the customer
+	Name  string
+	Email string // it's used to login
+	Phone string
+}
+
+type (
+	// Address address of the customer
+	Address struct {
+		City string
+	}
+	Region struct{}
+)
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "entity.go", src, parser.ParseComments)
+	assert.NoError(t, err)
+	docs := docComments([]*packages.Package{{Syntax: []*ast.File{file}}})
+	pos := map[string]token.Pos{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && ident.Obj != nil {
+			pos[ident.Name] = ident.Pos()
+		}
+		return true
+	})
+	assert.Equal(t, "Customer customer of the shop", docs[pos["Customer"]])
+	assert.Equal(t, "Name full name of the customer", docs[pos["Name"]])
+	assert.Equal(t, "it's used to login", docs[pos["Email"]])
+	assert.Empty(t, docs[pos["Phone"]])
+	assert.Equal(t, "Address address of the customer", docs[pos["Address"]])
+	assert.Empty(t, docs[pos["Region"]])
+}
+
+func TestDatasourceDirective(t *testing.T) {
+	src := `package entity
+
+// Customer customer of the shop
+//
+//dbo:datasource CRM
+type Customer struct {
+	Id int64
+}
+
+type (
+	//dbo:datasource sales
+	Order struct {
+		Id int64
+	}
+	Product struct {
+		Id int64
+	}
+)
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "entity.go", src, parser.ParseComments)
+	assert.NoError(t, err)
+	datasources := directives([]*packages.Package{{Syntax: []*ast.File{file}}})
+	for entity, ds := range map[string]string{"Customer": "crm", "Order": "sales", "Product": ""} {
+		assert.Equal(t, ds, datasources[file.Scope.Lookup(entity).Pos()], entity)
+	}
+}
+
+func TestDBO_Datasource(t *testing.T) {
+	dbo := newDBO(
+		Table{entity: "Customer", name: "customer", datasource: "crm", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+		}},
+		Table{entity: "Order", name: "orders", datasource: "sales", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "RegionId", B: "int64", C: "col=region_id;ref=Region.Id"},
+		}},
+		Table{entity: "Region", name: "region", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+		}},
+	).MustGet()
+	assert.Equal(t, []string{"crm", "sales"}, dbo.datasources())
+	entities := func(dbo DBO) []string {
+		return lo.Map(dbo.Tables(), func(t Table, _ int) string {
+			return t.Entity()
+		})
+	}
+	assert.Equal(t, []string{"Customer", "Region"}, entities(dbo.datasource("crm")))
+	assert.Equal(t, []string{"Region", "Order"}, entities(dbo.datasource("sales")))
+	dir := t.TempDir()
+	assert.NoError(t, dbo.ER(dir))
+	er := string(lo.Must(os.ReadFile(filepath.Join(dir, "sales", "er.d2"))))
+	assert.Contains(t, er, "orders.RegionId -> region.Id")
+	assert.NotContains(t, er, "customer")
+	assert.FileExists(t, filepath.Join(dir, "crm", "er.d2"))
+	assert.NoFileExists(t, filepath.Join(dir, "er.d2"))
+	assert.Equal(t, "sales", dbo.snapshot(1).dbo(nil).MustGet().Table("Order").Datasource())
+	invalid := newDBO(
+		Table{entity: "Customer", name: "customer", datasource: "crm", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+		}},
+		Table{entity: "Order", name: "orders", datasource: "sales", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "CustomerId", B: "int64", C: "col=customer_id;ref=Customer.Id"},
+		}},
+	)
+	assert.EqualError(t, invalid.Error(), "Order.CustomerId: can not reference Customer of datasource crm")
+}
+
+func TestBuildProblems(t *testing.T) {
+	dbo := newDBO(
+		Table{entity: "Customer", name: "customer", columns: []Column{
+			{A: "Name", B: "string", C: "col=name"},
+			{A: "NickName", B: "string", C: "col=name"},
+		}},
+		Table{entity: "Order", name: "orders", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "CustomerId", B: "int64", C: "col=customer_id;ref=Customer.Id"},
+			{A: "ProductId", B: "string", C: "col=product_id;ref=Product.Id"},
+			{A: "Code", B: "string", C: "col=code;ref=Product"},
+		}},
+		Table{entity: "Product", name: "product", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+		}},
+	)
+	var problems Problems
+	assert.ErrorAs(t, dbo.Error(), &problems)
+	assert.Equal(t, []string{
+		"Customer: no primary key",
+		"Customer.NickName: column name name is used by Name",
+		"Order.CustomerId: can not find attribute Id in Customer",
+		"Order.ProductId: type string is different from int64 of Product.Id",
+		"Order.Code: invalid column reference Product",
+	}, lo.Map(problems, func(p Problem, _ int) string {
+		return p.Message
+	}))
+	data, err := json.Marshal(Problem{Position: token.Position{Filename: "entity.go", Line: 3, Column: 2}, Entity: "Order", Message: "no column definition for Code"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"file":"entity.go","line":3,"column":2,"entity":"Order","message":"no column definition for Code"}`, string(data))
+}
+
+func TestQuoteIdentifiers(t *testing.T) {
+	dbo := newDBO(
+		Table{entity: "User", name: "user", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Key", B: "string", C: "col=key;uniq"},
+		}},
+		Table{entity: "Order", name: "order", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "UserId", B: "int64", C: "col=user_id;ref=User.Id"},
+		}},
+	).MustGet()
+	schema := func(db string) string {
+		var sb strings.Builder
+		assert.NoError(t, dbo.schema(db).MustGet().Execute(&sb, dbo))
+		return sb.String()
+	}
+	pg := schema("pg")
+	assert.Contains(t, pg, "create table \"user\"\n(\n    id  bigint not null generated always as identity,\n    key varchar(25) not null,")
+	assert.Contains(t, pg, "CONSTRAINT fk_order_user_id FOREIGN KEY (user_id) REFERENCES \"user\" (id)")
+	assert.Contains(t, pg, "create unique index uk_user_key on \"user\" (key);")
+	mysql := schema("mysql")
+	assert.Contains(t, mysql, "create table `order`")
+	assert.Contains(t, mysql, "    `key` varchar(25) not null,")
+	assert.Contains(t, mysql, "UNIQUE KEY uk_user_key (`key`)")
+	assert.Equal(t, []string{"drop table [order];", "drop table [user];"}, dbo.drop(DialectOf("sqlserver").MustGet()))
+	assert.Equal(t, []string{"alter table \"order\" drop column user_id;"}, diff(dbo, newDBO(
+		Table{entity: "User", name: "user", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Key", B: "string", C: "col=key;uniq"},
+		}},
+		Table{entity: "Order", name: "order", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+		}},
+	).MustGet(), "pg").MustGet().A[1:])
+}
+
+func TestDBO_Identifiers(t *testing.T) {
+	long := strings.Repeat("a", 64)
+	dbo := newDBO(
+		Table{entity: "User", name: "user", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Key", B: "string", C: "col=key"},
+			{A: "Long", B: "string", C: "col=" + long},
+		}},
+	).MustGet()
+	problems := dbo.identifiers(map[string][]string{"": {"pg", "mysql", "sqlite"}}, false)
+	assert.Equal(t, []string{
+		"table user is a reserved word of pg",
+		"column key is a reserved word of mysql, sqlite",
+		fmt.Sprintf("column %s is longer than 63 characters limit of pg", long),
+	}, lo.Map(problems, func(p Problem, _ int) string {
+		return strings.TrimSuffix(p.Message, ", rename it or enable dbo.quote")
+	}))
+	problems = dbo.identifiers(map[string][]string{"": {"sqlite"}}, true)
+	assert.Empty(t, problems)
+}
+
+func TestBuildRefCompatibility(t *testing.T) {
+	dbo := newDBO(
+		Table{entity: "Product", name: "product", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Code", B: "string", C: "col=code(25);uniq"},
+			{A: "Sku", B: "*string", C: "col=sku;uniq"},
+		}},
+		Table{entity: "Stock", name: "stock", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "ProductId", B: "int32", C: "col=product_id;ref=Product.Id"},
+			{A: "Code", B: "*string", C: "col=code(10);ref=Product.Code"},
+			{A: "Sku", B: "string", C: "col=sku;ref=Product.Sku"},
+		}},
+	)
+	var problems Problems
+	assert.ErrorAs(t, dbo.Error(), &problems)
+	assert.Equal(t, []string{
+		"Stock.ProductId: type int32 is different from int64 of Product.Id",
+		"Stock.Code: precision (10) is different from (25) of Product.Code",
+		"Stock.Sku: can not reference nullable column Product.Sku",
+	}, lo.Map(problems, func(p Problem, _ int) string {
+		return p.Message
+	}))
+	status := Enum{name: "OrderStatus", typ: "string", values: []string{"created", "paid"}}
+	state := Enum{name: "PaymentState", typ: "string", values: []string{"created", "paid"}}
+	dbo = newDBO(
+		Table{entity: "Order", name: "orders", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Status", B: "entity.OrderStatus", C: "col=status(10);uniq", enum: mo.Some(status)},
+			{A: "Remark", B: "string", C: "col=remark(10);uniq"},
+		}},
+		Table{entity: "Payment", name: "payment", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Status", B: "entity.OrderStatus", C: "col=status(10);ref=Order.Status", enum: mo.Some(status)},
+			{A: "State", B: "entity.PaymentState", C: "col=state(10);ref=Order.Status", enum: mo.Some(state)},
+			{A: "Remark", B: "entity.OrderStatus", C: "col=remark(10);ref=Order.Remark", enum: mo.Some(status)},
+		}},
+	)
+	assert.ErrorAs(t, dbo.Error(), &problems)
+	assert.Equal(t, []string{
+		"Payment.State: type entity.PaymentState is different from entity.OrderStatus of Order.Status",
+		"Payment.Remark: type entity.OrderStatus is different from string of Order.Remark",
+	}, lo.Map(problems, func(p Problem, _ int) string {
+		return p.Message
+	}))
+	assert.True(t, newDBO(
+		Table{entity: "Product", name: "product", columns: []Column{
+			{A: "Code", B: "string", C: "col=code(25);pk"},
+		}},
+		Table{entity: "Stock", name: "stock", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Code", B: "*string", C: "col=code(25);ref=Product.Code"},
+		}},
+	).IsOk())
+	// absent precision is the default precision of the type mapping
+	assert.True(t, newDBO(
+		Table{entity: "Product", name: "product", columns: []Column{
+			{A: "Code", B: "string", C: "col=code(25);pk"},
+		}},
+		Table{entity: "Stock", name: "stock", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Code", B: "string", C: "col=code;ref=Product.Code"},
+		}},
+	).IsOk())
+	dbo = newDBO(
+		Table{entity: "Product", name: "product", columns: []Column{
+			{A: "Code", B: "string", C: "col=code(20);pk"},
+		}},
+		Table{entity: "Stock", name: "stock", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Code", B: "string", C: "col=code;ref=Product.Code"},
+		}},
+	)
+	assert.ErrorAs(t, dbo.Error(), &problems)
+	assert.Equal(t, "Stock.Code: precision default is different from (20) of Product.Code", problems[0].Message)
+}
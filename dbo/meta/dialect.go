@@ -0,0 +1,605 @@
+package meta
+
+import (
+	"fmt"
+	"github.com/samber/lo"
+	"github.com/samber/mo"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Dialect sql dialect of a database platform
+type Dialect interface {
+	// Name platform name of the dialect, it's the value of `datasource.*.db` in the configuration
+	Name() string
+	// Types builtin sql types of go types
+	Types() map[GoType]string
+	// Quote quote the identifier
+	Quote(ident string) string
+	// Ident identifier in statements, it's quoted when it's a reserved word or has special characters
+	Ident(ident string) string
+	// Reserved identify the identifier is a reserved word of the platform, it's case-insensitive
+	Reserved(ident string) bool
+	// MaxIdent max length of identifiers, longer identifiers are truncated or rejected. it's 0 when there is no limit
+	MaxIdent() int
+	// Identity auto increment clause of the column definition
+	Identity() string
+	// Bind bind variable of the i-th(starts from 1) parameter
+	Bind(i int) string
+	// Drivers database drivers of the platform
+	Drivers() []DBType
+	// InlineIndex indexes are defined in create table statement
+	InlineIndex() bool
+	// AddColumn add column statement, table and column are the identifiers returned by Ident
+	AddColumn(table, column, def string) string
+	// AlterColumn alter column statements
+	AlterColumn(t Table, from, to Column) []string
+	// RenameTable rename table statement, the table names are quoted by the dialect when it's required
+	RenameTable(from, to string) string
+	// DropIndex drop index statement, table is the identifier returned by Ident
+	DropIndex(table string, idx Index) string
+	// AddForeignKey add foreign key statement, table is the identifier returned by Ident
+	AddForeignKey(table string, fk ForeignKey) string
+	// Literal sql literal of the value of the go type
+	Literal(typ GoType, value string) string
+	// AlterDefault statement to set or drop the default value of the column, table is the identifier returned by Ident
+	AlterDefault(table string, c Column) string
+	// OnUpdate clause of the column definition to set current timestamp on update
+	OnUpdate() string
+	// Trigger statements to create the trigger which sets current timestamp to the column on update
+	Trigger(t Table, c Column) []string
+	// DropTrigger statements to drop the trigger of the column
+	DropTrigger(t Table, c Column) []string
+	// Remark sql comment of the doc, it's used when the dialect does not store comments
+	Remark(doc string) string
+	// Comment comment clause of the column definition and table options
+	Comment(doc string) string
+	// InlineComment identify comments are defined by comment clause rather than comment on statements
+	InlineComment() bool
+	// CommentOn statements to comment on the table(column is absent) or the column
+	CommentOn(t Table, c mo.Option[Column]) []string
+	// NativeEnum identify string enums are created as types rather than checked by constraint
+	NativeEnum() bool
+	// CreateEnum statement to create the enum type
+	CreateEnum(e Enum) string
+	// AlterEnum statements to add the new values of the enum type
+	AlterEnum(from, to Enum) []string
+	// DropEnum statement to drop the enum type
+	DropEnum(e Enum) string
+	// DropForeignKey drop foreign key statement, table is the identifier returned by Ident
+	DropForeignKey(table string, fk ForeignKey) string
+}
+
+var (
+	dialects  = map[string]Dialect{}
+	dialectMu sync.RWMutex
+	// plainIdentReg identifiers can be used without quotes
+	plainIdentReg = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// RegisterDialect register the dialect, the dialect with the same name is replaced
+func RegisterDialect(d Dialect) {
+	dialectMu.Lock()
+	defer dialectMu.Unlock()
+	dialects[d.Name()] = d
+}
+
+// DialectOf return the dialect of the platform
+func DialectOf(platform string) mo.Option[Dialect] {
+	dialectMu.RLock()
+	defer dialectMu.RUnlock()
+	d, ok := dialects[platform]
+	return lo.If(ok, mo.Some(d)).Else(mo.None[Dialect]())
+}
+
+// Dialects return all registered dialects in name order
+func Dialects() []Dialect {
+	dialectMu.RLock()
+	defer dialectMu.RUnlock()
+	ds := lo.Values(dialects)
+	slices.SortFunc(ds, func(a, b Dialect) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return ds
+}
+
+func init() {
+	for _, d := range []Dialect{
+		pg{ansi{name: "pg", types: pgTypes, identity: "generated always as identity", reserved: pgReserved, maxIdent: 63}},
+		pg{ansi{name: "cockroach", types: pgTypes, identity: "generated always as identity", reserved: pgReserved, maxIdent: 63}},
+		duckdb{ansi{name: "duckdb", types: duckdbTypes, reserved: pgReserved}},
+		mysql{ansi{name: "mysql", types: mysqlTypes, identity: "auto_increment", quote: "``", reserved: mysqlReserved, maxIdent: 64}},
+		mysql{ansi{name: "mariadb", types: mysqlTypes, identity: "auto_increment", quote: "``", reserved: mysqlReserved, maxIdent: 64}},
+		sqlite{ansi{name: "sqlite", types: sqliteTypes, reserved: sqliteReserved}},
+		sqlserver{ansi{name: "sqlserver", types: sqlserverTypes, identity: "identity(1, 1)", quote: "[]", reserved: sqlserverReserved, maxIdent: 128}},
+	} {
+		RegisterDialect(d)
+	}
+}
+
+// ansi dialect follows the sql standard, it's the base of other dialects
+type ansi struct {
+	name     string
+	types    map[GoType]string
+	identity string
+	// quote opening and closing quote characters of identifiers, it's "" by default
+	quote    string
+	reserved []string
+	maxIdent int
+}
+
+func (d ansi) Name() string {
+	return d.name
+}
+
+func (d ansi) Types() map[GoType]string {
+	return d.types
+}
+
+func (d ansi) Quote(ident string) string {
+	quote := lo.CoalesceOrEmpty(d.quote, `""`)
+	return quote[:1] + strings.ReplaceAll(ident, quote[1:], quote[1:]+quote[1:]) + quote[1:]
+}
+
+func (d ansi) Ident(ident string) string {
+	return lo.If(d.Reserved(ident) || !plainIdentReg.MatchString(ident), d.Quote(ident)).Else(ident)
+}
+
+func (d ansi) Reserved(ident string) bool {
+	return slices.Contains(d.reserved, strings.ToLower(ident))
+}
+
+func (d ansi) MaxIdent() int {
+	return d.maxIdent
+}
+
+func (d ansi) Identity() string {
+	return d.identity
+}
+
+func (d ansi) Bind(_ int) string {
+	return "?"
+}
+
+func (d ansi) Drivers() []DBType {
+	return lo.Filter(SupportedDB(), func(db DBType, _ int) bool {
+		return db.DB == d.name
+	})
+}
+
+func (d ansi) InlineIndex() bool {
+	return false
+}
+
+func (d ansi) AddColumn(table, column, def string) string {
+	return fmt.Sprintf("alter table %s add column %s %s;", table, column, def)
+}
+
+func (d ansi) AlterColumn(t Table, from, to Column) []string {
+	var stmts []string
+	if from.Type(d.name) != to.Type(d.name) {
+		stmts = append(stmts, fmt.Sprintf("alter table %s alter column %s type %s;", d.Ident(t.Name()), d.Ident(to.Name()), to.Type(d.name)))
+	}
+	if from.Nullable() != to.Nullable() {
+		stmts = append(stmts, fmt.Sprintf("alter table %s alter column %s %s not null;", d.Ident(t.Name()), d.Ident(to.Name()), lo.If(to.Nullable(), "drop").Else("set")))
+	}
+	return stmts
+}
+
+func (d ansi) RenameTable(from, to string) string {
+	return fmt.Sprintf("alter table %s rename to %s;", d.Ident(from), d.Ident(to))
+}
+
+func (d ansi) DropIndex(_ string, idx Index) string {
+	return fmt.Sprintf("drop index %s;", d.Ident(idx.Name()))
+}
+
+func (d ansi) AddForeignKey(table string, fk ForeignKey) string {
+	return fmt.Sprintf("alter table %s add constraint %s foreign key (%s) references %s (%s)%s;",
+		table, d.Ident(fk.Name()), d.Ident(fk.Column()), d.Ident(fk.RefTable()), d.Ident(fk.RefColumn()), fk.Actions())
+}
+
+func (d ansi) DropForeignKey(table string, fk ForeignKey) string {
+	return fmt.Sprintf("alter table %s drop constraint %s;", table, d.Ident(fk.Name()))
+}
+
+func (d ansi) Literal(typ GoType, value string) string {
+	switch {
+	case typ == "bool", slices.Contains(intTypes, typ), slices.Contains(uintTypes, typ), slices.Contains(floatTypes, typ):
+		return value
+	case typ == "time.Time" && strings.EqualFold(value, "now"):
+		return "current_timestamp"
+	default:
+		return literal(value)
+	}
+}
+
+func (d ansi) AlterDefault(table string, c Column) string {
+	if v := c.Default(d.name); v.IsPresent() {
+		return fmt.Sprintf("alter table %s alter column %s set default %s;", table, d.Ident(c.Name()), v.MustGet())
+	}
+	return fmt.Sprintf("alter table %s alter column %s drop default;", table, d.Ident(c.Name()))
+}
+
+func (d ansi) OnUpdate() string {
+	return ""
+}
+
+func (d ansi) Trigger(t Table, c Column) []string {
+	return []string{fmt.Sprintf("-- %s does not support updating column %s.%s automatically, it should be set by application", d.name, t.Name(), c.Name())}
+}
+
+func (d ansi) DropTrigger(_ Table, _ Column) []string {
+	return nil
+}
+
+// trigger name of the trigger maintaining the column
+func trigger(d Dialect, t Table, c Column) string {
+	return d.Ident(fmt.Sprintf("trg_%s_%s", lo.SnakeCase(t.Entity()), c.Name()))
+}
+
+func (d ansi) Remark(doc string) string {
+	return lo.If(len(doc) > 0, fmt.Sprintf("-- %s", doc)).Else("")
+}
+
+func (d ansi) Comment(_ string) string {
+	return ""
+}
+
+func (d ansi) InlineComment() bool {
+	return false
+}
+
+func (d ansi) CommentOn(_ Table, _ mo.Option[Column]) []string {
+	return nil
+}
+
+// commentOn comment on statement of sql standard
+func commentOn(d Dialect, t Table, c mo.Option[Column]) []string {
+	if c.IsPresent() {
+		return []string{fmt.Sprintf("comment on column %s.%s is %s;", d.Ident(t.Name()), d.Ident(c.MustGet().Name()), literal(c.MustGet().doc))}
+	}
+	return []string{fmt.Sprintf("comment on table %s is %s;", d.Ident(t.Name()), literal(t.doc))}
+}
+
+// literal quoted string literal
+func literal(value string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", "''"))
+}
+
+func (d ansi) NativeEnum() bool {
+	return false
+}
+
+func (d ansi) CreateEnum(e Enum) string {
+	return fmt.Sprintf("create type %s as enum (%s);", d.Ident(e.Name()), e.Literals())
+}
+
+func (d ansi) AlterEnum(from, to Enum) []string {
+	return lo.Map(lo.Without(to.values, from.values...), func(v string, _ int) string {
+		return fmt.Sprintf("alter type %s add value %s;", d.Ident(to.Name()), Enum{typ: to.typ, values: []string{v}}.Literals())
+	})
+}
+
+func (d ansi) DropEnum(e Enum) string {
+	return fmt.Sprintf("drop type %s;", d.Ident(e.Name()))
+}
+
+// pg dialect of PostgreSQL and CockroachDB
+type pg struct {
+	ansi
+}
+
+func (d pg) NativeEnum() bool {
+	return true
+}
+
+func (d pg) Remark(_ string) string {
+	return ""
+}
+
+func (d pg) CommentOn(t Table, c mo.Option[Column]) []string {
+	return commentOn(d, t, c)
+}
+
+func (d pg) Trigger(t Table, c Column) []string {
+	return []string{
+		fmt.Sprintf(`create or replace function %s() returns trigger as $$
+begin
+    new.%s = current_timestamp;
+    return new;
+end;
+$$ language plpgsql;`, trigger(d, t, c), d.Ident(c.Name())),
+		fmt.Sprintf("create trigger %s before update on %s for each row execute function %s();", trigger(d, t, c), d.Ident(t.Name()), trigger(d, t, c)),
+	}
+}
+
+func (d pg) DropTrigger(t Table, c Column) []string {
+	return []string{
+		fmt.Sprintf("drop trigger %s on %s;", trigger(d, t, c), d.Ident(t.Name())),
+		fmt.Sprintf("drop function %s();", trigger(d, t, c)),
+	}
+}
+
+func (d pg) Bind(i int) string {
+	return fmt.Sprintf("$%d", i)
+}
+
+// duckdb dialect of DuckDB, it does not support altering constraint
+type duckdb struct {
+	ansi
+}
+
+func (d duckdb) NativeEnum() bool {
+	return true
+}
+
+func (d duckdb) Remark(_ string) string {
+	return ""
+}
+
+func (d duckdb) CommentOn(t Table, c mo.Option[Column]) []string {
+	return commentOn(d, t, c)
+}
+
+func (d duckdb) AlterEnum(_, to Enum) []string {
+	return []string{fmt.Sprintf("-- %s does not support altering enum %s, it needs to be recreated", d.name, to.Name())}
+}
+
+func (d duckdb) AddForeignKey(table string, fk ForeignKey) string {
+	return fmt.Sprintf("-- %s does not support adding constraint %s, table %s needs to be rebuilt", d.name, fk.Name(), table)
+}
+
+func (d duckdb) DropForeignKey(table string, fk ForeignKey) string {
+	return fmt.Sprintf("-- %s does not support dropping constraint %s, table %s needs to be rebuilt", d.name, fk.Name(), table)
+}
+
+// mysql dialect of MySQL and MariaDB
+type mysql struct {
+	ansi
+}
+
+func (d mysql) OnUpdate() string {
+	return "on update current_timestamp"
+}
+
+func (d mysql) Trigger(_ Table, _ Column) []string {
+	return nil
+}
+
+func (d mysql) Remark(_ string) string {
+	return ""
+}
+
+func (d mysql) Comment(doc string) string {
+	return fmt.Sprintf("comment %s", literal(doc))
+}
+
+func (d mysql) InlineComment() bool {
+	return true
+}
+
+// CommentOn comments are defined by comment clause, the column is redefined to change its comment
+func (d mysql) CommentOn(t Table, c mo.Option[Column]) []string {
+	if c.IsPresent() {
+		return d.AlterColumn(t, c.MustGet(), c.MustGet())
+	}
+	return []string{fmt.Sprintf("alter table %s %s;", d.Ident(t.Name()), d.Comment(t.doc))}
+}
+
+func (d mysql) InlineIndex() bool {
+	return true
+}
+
+func (d mysql) AlterColumn(t Table, _, to Column) []string {
+	return []string{fmt.Sprintf("alter table %s modify column %s %s;", d.Ident(t.Name()), d.Ident(to.Name()), t.ColumnDef(to, d.name))}
+}
+
+func (d mysql) DropIndex(table string, idx Index) string {
+	return fmt.Sprintf("drop index %s on %s;", d.Ident(idx.Name()), table)
+}
+
+func (d mysql) DropForeignKey(table string, fk ForeignKey) string {
+	return fmt.Sprintf("alter table %s drop foreign key %s;", table, d.Ident(fk.Name()))
+}
+
+// sqlite dialect of SQLite, it does not support altering column and constraint
+type sqlite struct {
+	ansi
+}
+
+func (d sqlite) AlterColumn(t Table, _, to Column) []string {
+	return []string{fmt.Sprintf("-- %s does not support altering column %s, table %s needs to be rebuilt", d.name, to.Name(), t.Name())}
+}
+
+func (d sqlite) Trigger(t Table, c Column) []string {
+	table, column := d.Ident(t.Name()), d.Ident(c.Name())
+	return []string{fmt.Sprintf(`create trigger %s after update on %s for each row when new.%s = old.%s
+begin
+    update %s set %s = current_timestamp where %s;
+end;`, trigger(d, t, c), table, column, column, table, column, strings.Join(lo.Map(t.PKColumns(), func(pk Column, _ int) string {
+		return fmt.Sprintf("%s = new.%s", d.Ident(pk.Name()), d.Ident(pk.Name()))
+	}), " and "))}
+}
+
+func (d sqlite) DropTrigger(t Table, c Column) []string {
+	return []string{fmt.Sprintf("drop trigger %s;", trigger(d, t, c))}
+}
+
+func (d sqlite) Literal(typ GoType, value string) string {
+	if typ == "bool" {
+		return lo.If(value == "true", "1").Else("0")
+	}
+	return d.ansi.Literal(typ, value)
+}
+
+func (d sqlite) AlterDefault(table string, c Column) string {
+	return fmt.Sprintf("-- %s does not support altering default value of column %s, table %s needs to be rebuilt", d.name, c.Name(), table)
+}
+
+func (d sqlite) AddForeignKey(table string, fk ForeignKey) string {
+	return fmt.Sprintf("-- %s does not support adding constraint %s, table %s needs to be rebuilt", d.name, fk.Name(), table)
+}
+
+func (d sqlite) DropForeignKey(table string, fk ForeignKey) string {
+	return fmt.Sprintf("-- %s does not support dropping constraint %s, table %s needs to be rebuilt", d.name, fk.Name(), table)
+}
+
+// sqlserver dialect of SQL Server
+type sqlserver struct {
+	ansi
+}
+
+func (d sqlserver) Bind(i int) string {
+	return fmt.Sprintf("@p%d", i)
+}
+
+func (d sqlserver) AddColumn(table, column, def string) string {
+	return fmt.Sprintf("alter table %s add %s %s;", table, column, def)
+}
+
+func (d sqlserver) AlterColumn(t Table, _, to Column) []string {
+	return []string{fmt.Sprintf("alter table %s alter column %s %s %s;", d.Ident(t.Name()), d.Ident(to.Name()), to.Type(d.name), lo.If(to.Nullable(), "null").Else("not null"))}
+}
+
+func (d sqlserver) Literal(typ GoType, value string) string {
+	if typ == "bool" {
+		return lo.If(value == "true", "1").Else("0")
+	}
+	return d.ansi.Literal(typ, value)
+}
+
+func (d sqlserver) AlterDefault(table string, c Column) string {
+	if v := c.Default(d.name); v.IsPresent() {
+		return fmt.Sprintf("alter table %s add default %s for %s;", table, v.MustGet(), d.Ident(c.Name()))
+	}
+	return fmt.Sprintf("-- %s does not support dropping unnamed default constraint of column %s, please migrate it manually", d.name, c.Name())
+}
+
+func (d sqlserver) RenameTable(from, to string) string {
+	return fmt.Sprintf("exec sp_rename '%s', '%s';", from, to)
+}
+
+func (d sqlserver) DropIndex(table string, idx Index) string {
+	return fmt.Sprintf("drop index %s on %s;", d.Ident(idx.Name()), table)
+}
+
+var (
+	mysqlTypes = map[GoType]string{
+		"string":                                "varchar(25)",
+		"bool":                                  "boolean",
+		"int8":                                  "tinyint",
+		"uint8":                                 "tinyint unsigned", // unsigned, not a SQL standard
+		"byte":                                  "tinyint unsigned", // unsigned, not a SQL standard
+		"int16":                                 "smallint",
+		"uint16":                                "smallint",
+		"int32":                                 "int",
+		"rune":                                  "int",
+		"uint32":                                "int",
+		"int64":                                 "bigint",
+		"int":                                   "bigint",
+		"uint64":                                "bigint",
+		"float32":                               "decimal(38, 2)",
+		"float64":                               "decimal(38, 2)",
+		"time.Time":                             "timestamp",
+		"time.Duration":                         "bigint",
+		"[]byte":                                "blob",
+		"encoding/json.RawMessage":              "json",
+		"github.com/google/uuid.UUID":           "char(36)",
+		"github.com/shopspring/decimal.Decimal": "decimal(38, 2)",
+	}
+	pgTypes = map[GoType]string{
+		"string":                                "varchar(25)",
+		"bool":                                  "boolean",
+		"int8":                                  "int8",
+		"uint8":                                 "int8",
+		"byte":                                  "int8",
+		"int16":                                 "smallint",
+		"uint16":                                "smallint",
+		"int32":                                 "integer",
+		"rune":                                  "integer",
+		"uint32":                                "integer",
+		"int64":                                 "bigint",
+		"int":                                   "bigint",
+		"uint64":                                "bigint",
+		"float32":                               "decimal(38, 2)",
+		"float64":                               "decimal(38, 2)",
+		"time.Time":                             "timestamp",
+		"time.Duration":                         "bigint",
+		"[]byte":                                "bytea",
+		"encoding/json.RawMessage":              "jsonb",
+		"github.com/google/uuid.UUID":           "uuid",
+		"github.com/shopspring/decimal.Decimal": "decimal(38, 2)",
+	}
+	sqliteTypes = map[GoType]string{
+		"string":                                "text(25)",
+		"bool":                                  "integer",
+		"int8":                                  "integer",
+		"uint8":                                 "integer",
+		"byte":                                  "integer",
+		"int16":                                 "integer",
+		"uint16":                                "integer",
+		"int32":                                 "integer",
+		"rune":                                  "integer",
+		"uint32":                                "integer",
+		"int64":                                 "integer",
+		"int":                                   "integer",
+		"uint64":                                "integer",
+		"float32":                               "decimal(38, 2)",
+		"float64":                               "decimal(38, 2)",
+		"time.Time":                             "datetime",
+		"time.Duration":                         "integer",
+		"[]byte":                                "blob",
+		"encoding/json.RawMessage":              "text",
+		"github.com/google/uuid.UUID":           "text(36)",
+		"github.com/shopspring/decimal.Decimal": "decimal(38, 2)",
+	}
+	sqlserverTypes = map[GoType]string{
+		"string":                                "nvarchar(25)",
+		"bool":                                  "bit",
+		"int8":                                  "smallint",
+		"uint8":                                 "tinyint",
+		"byte":                                  "tinyint",
+		"int16":                                 "smallint",
+		"uint16":                                "int",
+		"int32":                                 "int",
+		"rune":                                  "int",
+		"uint32":                                "bigint",
+		"int64":                                 "bigint",
+		"int":                                   "bigint",
+		"uint64":                                "decimal(20, 0)",
+		"float32":                               "decimal(38, 2)",
+		"float64":                               "decimal(38, 2)",
+		"time.Time":                             "datetime2",
+		"time.Duration":                         "bigint",
+		"[]byte":                                "varbinary(max)",
+		"encoding/json.RawMessage":              "nvarchar(max)",
+		"github.com/google/uuid.UUID":           "uniqueidentifier",
+		"github.com/shopspring/decimal.Decimal": "decimal(38, 2)",
+	}
+	duckdbTypes = map[GoType]string{
+		"string":                                "varchar(25)",
+		"bool":                                  "boolean",
+		"int8":                                  "tinyint",
+		"uint8":                                 "utinyint",
+		"byte":                                  "utinyint",
+		"int16":                                 "smallint",
+		"uint16":                                "usmallint",
+		"int32":                                 "integer",
+		"rune":                                  "integer",
+		"uint32":                                "uinteger",
+		"int64":                                 "bigint",
+		"int":                                   "bigint",
+		"uint64":                                "ubigint",
+		"float32":                               "decimal(38, 2)",
+		"float64":                               "decimal(38, 2)",
+		"time.Time":                             "timestamp",
+		"time.Duration":                         "bigint",
+		"[]byte":                                "blob",
+		"encoding/json.RawMessage":              "json",
+		"github.com/google/uuid.UUID":           "uuid",
+		"github.com/shopspring/decimal.Decimal": "decimal(38, 2)",
+	}
+)
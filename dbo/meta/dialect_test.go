@@ -0,0 +1,95 @@
+package meta
+
+import (
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDialects(t *testing.T) {
+	names := lo.Map(Dialects(), func(d Dialect, _ int) string {
+		return d.Name()
+	})
+	assert.Equal(t, []string{"cockroach", "duckdb", "mariadb", "mysql", "pg", "sqlite", "sqlserver"}, names)
+	assert.True(t, DialectOf("oracle").IsAbsent())
+	for _, d := range Dialects() {
+		assert.NotEmpty(t, d.Drivers(), d.Name())
+	}
+}
+
+func TestDialect(t *testing.T) {
+	tests := []struct {
+		name     string
+		quote    string
+		bind     string
+		identity string
+		inline   bool
+	}{
+		{"pg", `"order"`, "$2", "generated always as identity", false},
+		{"cockroach", `"order"`, "$2", "generated always as identity", false},
+		{"mysql", "`order`", "?", "auto_increment", true},
+		{"mariadb", "`order`", "?", "auto_increment", true},
+		{"sqlite", `"order"`, "?", "", false},
+		{"sqlserver", "[order]", "@p2", "identity(1, 1)", false},
+		{"duckdb", `"order"`, "?", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := DialectOf(test.name).MustGet()
+			assert.Equal(t, test.quote, d.Quote("order"))
+			assert.Equal(t, test.bind, d.Bind(2))
+			assert.Equal(t, test.identity, d.Identity())
+			// indexes and comments are both inline clauses of mysql family
+			assert.Equal(t, test.inline, d.InlineIndex())
+			assert.Equal(t, test.inline, d.InlineComment())
+		})
+	}
+}
+
+func TestDialectStatements(t *testing.T) {
+	table := Table{entity: "Customer", name: "customer", columns: []Column{
+		{A: "ID", B: "int64", C: "col=id;pk"},
+		{A: "Name", B: "string", C: "col=name(50)"},
+	}}
+	from, to := table.columns[1], Column{A: "Name", B: "*string", C: "col=name(80)"}
+	idx := Index{name: "idx_customer_name", columns: []string{"name"}}
+	fk := ForeignKey{name: "fk_order_customer_id", column: "customer_id", refTable: "customer", refColumn: "id"}
+	sqlserver := DialectOf("sqlserver").MustGet()
+	assert.Equal(t, "alter table customer add email nvarchar(25);", sqlserver.AddColumn("customer", "email", "nvarchar(25)"))
+	assert.Equal(t, []string{"alter table customer alter column name nvarchar(80) null;"}, sqlserver.AlterColumn(table, from, to))
+	assert.Equal(t, "exec sp_rename 'customer', 'client';", sqlserver.RenameTable("customer", "client"))
+	assert.Equal(t, "drop index idx_customer_name on customer;", sqlserver.DropIndex("customer", idx))
+	pg := DialectOf("cockroach").MustGet()
+	assert.Equal(t, []string{"alter table customer alter column name type varchar(80);", "alter table customer alter column name drop not null;"}, pg.AlterColumn(table, from, to))
+	assert.Equal(t, "drop index idx_customer_name;", pg.DropIndex("customer", idx))
+	duckdb := DialectOf("duckdb").MustGet()
+	assert.Contains(t, duckdb.AddForeignKey("order", fk), "-- duckdb does not support adding constraint fk_order_customer_id")
+	mariadb := DialectOf("mariadb").MustGet()
+	assert.Equal(t, "alter table order drop foreign key fk_order_customer_id;", mariadb.DropForeignKey("order", fk))
+}
+
+func TestDialect_Ident(t *testing.T) {
+	tests := []struct {
+		name     string
+		ident    string
+		expected string
+	}{
+		{"pg", "name", "name"},
+		{"pg", "User", `"User"`},
+		{"pg", "first name", `"first name"`},
+		{"mysql", "key", "`key`"},
+		{"mysql", "user", "user"},
+		{"mysql", "a`b", "`a``b`"},
+		{"sqlite", "order", `"order"`},
+		{"sqlserver", "user", "[user]"},
+		{"sqlserver", "a]b", "[a]]b]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name+"_"+test.ident, func(t *testing.T) {
+			assert.Equal(t, test.expected, DialectOf(test.name).MustGet().Ident(test.ident))
+		})
+	}
+	assert.Equal(t, 63, DialectOf("pg").MustGet().MaxIdent())
+	assert.Equal(t, 64, DialectOf("mariadb").MustGet().MaxIdent())
+	assert.Zero(t, DialectOf("sqlite").MustGet().MaxIdent())
+}
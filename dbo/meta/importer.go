@@ -0,0 +1,275 @@
+package meta
+
+import (
+	"bytes"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"github.com/samber/lo"
+	"github.com/samber/mo"
+	"go/format"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+var (
+	//go:embed entity.tmpl
+	entityTmpl string
+
+	// preferred go types when a sql type is mapped by several go types
+	preferred = []GoType{"string", "int64", "float64", "bool", "time.Time"}
+
+	// dialects whose type mappings are used to resolve the go type of a sqlite column, in order
+	dialectPreferred = []string{"sqlite", "pg", "mysql"}
+
+	nullTypes = map[GoType]string{
+		"string":  "sql.NullString",
+		"int64":   "sql.NullInt64",
+		"int32":   "sql.NullInt32",
+		"int16":   "sql.NullInt16",
+		"float64": "sql.NullFloat64",
+		"bool":    "sql.NullBool",
+		"byte":    "sql.NullByte",
+	}
+)
+
+type Field lo.Tuple3[string, string, string]
+
+// Name go struct attribute name
+func (f Field) Name() string {
+	return f.A
+}
+
+// Type go type of the attribute
+func (f Field) Type() string {
+	return f.B
+}
+
+// Tag db tag of the attribute
+func (f Field) Tag() string {
+	return f.C
+}
+
+// Entity go struct generated from a database table
+type Entity struct {
+	Name    string
+	Table   string
+	Package string
+	Fields  []Field
+}
+
+// Imports packages used by the entity
+func (e Entity) Imports() []string {
+	imports := []string{"github.com/kcmvp/dbo/base"}
+	for _, f := range e.Fields {
+		if strings.HasPrefix(strings.TrimPrefix(f.Type(), "*"), "sql.") {
+			imports = append(imports, "database/sql")
+		} else if strings.HasPrefix(strings.TrimPrefix(f.Type(), "*"), "time.") {
+			imports = append(imports, "time")
+		}
+	}
+	imports = lo.Uniq(imports)
+	slices.Sort(imports)
+	return imports
+}
+
+// inverseType resolve go type of the sql type by the inverse of the type mappings, the sql type
+// which can not be found in the type mappings is resolved by the sqlite type affinity
+func inverseType(mappings typeMappings, sqlType string) mo.Option[GoType] {
+	base := strings.TrimSpace(preReg.ReplaceAllString(strings.ToLower(sqlType), ""))
+	// types with package path(uuid.UUID, json.RawMessage...) are never inferred
+	candidates := lo.Filter(mappings.all(), func(m TypeMapping, _ int) bool {
+		return !strings.Contains(string(m.A), "/")
+	})
+	slices.SortStableFunc(candidates, func(a, b TypeMapping) int {
+		ia, ib := slices.Index(preferred, a.A), slices.Index(preferred, b.A)
+		return lo.If(ia < 0, len(preferred)).Else(ia) - lo.If(ib < 0, len(preferred)).Else(ib)
+	})
+	for _, m := range candidates {
+		for _, typ := range lo.FilterMap(dialectPreferred, func(d string, _ int) (string, bool) {
+			typ, ok := m.B[d]
+			return typ, ok
+		}) {
+			if strings.TrimSpace(preReg.ReplaceAllString(strings.ToLower(typ), "")) == base {
+				return mo.Some(m.A)
+			}
+		}
+	}
+	// https://www.sqlite.org/datatype3.html#determination_of_column_affinity
+	switch {
+	case strings.Contains(base, "int"):
+		return mo.Some[GoType]("int64")
+	case strings.Contains(base, "char"), strings.Contains(base, "clob"), strings.Contains(base, "text"):
+		return mo.Some[GoType]("string")
+	case strings.Contains(base, "real"), strings.Contains(base, "floa"), strings.Contains(base, "doub"):
+		return mo.Some[GoType]("float64")
+	}
+	return mo.None[GoType]()
+}
+
+// entity read table metadata from sqlite database and build the entity, go types are resolved by the mappings
+func entity(db *sql.DB, table, pkg string, mappings typeMappings) mo.Result[Entity] {
+	type column struct {
+		name    string
+		typ     string
+		notNull bool
+		pk      int
+	}
+	var columns []column
+	rows, err := db.Query(fmt.Sprintf("select name, type, \"notnull\", pk from pragma_table_info(%s) order by cid", literal(table)))
+	if err != nil {
+		return mo.Err[Entity](err)
+	}
+	for rows.Next() {
+		var c column
+		if err = rows.Scan(&c.name, &c.typ, &c.notNull, &c.pk); err != nil {
+			rows.Close()
+			return mo.Err[Entity](err)
+		}
+		columns = append(columns, c)
+	}
+	rows.Close()
+	tags := map[string][]string{}
+	// foreign keys, referenced column is the primary key when it's absent
+	rows, err = db.Query(fmt.Sprintf("select \"table\", \"from\", coalesce(\"to\", ''), on_update, on_delete from pragma_foreign_key_list(%s)", literal(table)))
+	if err != nil {
+		return mo.Err[Entity](err)
+	}
+	for rows.Next() {
+		var refTable, from, to, onUpdate, onDelete string
+		if err = rows.Scan(&refTable, &from, &to, &onUpdate, &onDelete); err != nil {
+			rows.Close()
+			return mo.Err[Entity](err)
+		}
+		if len(to) == 0 {
+			if err = db.QueryRow(fmt.Sprintf("select name from pragma_table_info(%s) where pk = 1", literal(refTable))).Scan(&to); err != nil {
+				rows.Close()
+				return mo.Err[Entity](fmt.Errorf("%s: can not resolve reference of %s: %w", table, from, err))
+			}
+		}
+		tags[from] = append(tags[from], fmt.Sprintf("ref=%s.%s", lo.PascalCase(refTable), lo.PascalCase(to)))
+		for _, action := range []lo.Tuple2[ColProperty, string]{{A: colOnDelete, B: onDelete}, {A: colOnUpdate, B: onUpdate}} {
+			if !strings.EqualFold(action.B, "no action") {
+				tags[from] = append(tags[from], fmt.Sprintf("%s=%s", action.A, strings.ToLower(action.B)))
+			}
+		}
+	}
+	rows.Close()
+	// indexes created explicitly or by unique constraints
+	rows, err = db.Query(fmt.Sprintf("select name, \"unique\", origin from pragma_index_list(%s) where origin in ('c', 'u')", literal(table)))
+	if err != nil {
+		return mo.Err[Entity](err)
+	}
+	var indexes []lo.Tuple3[string, bool, string]
+	for rows.Next() {
+		var index lo.Tuple3[string, bool, string]
+		if err = rows.Scan(&index.A, &index.B, &index.C); err != nil {
+			rows.Close()
+			return mo.Err[Entity](err)
+		}
+		indexes = append(indexes, index)
+	}
+	rows.Close()
+	for _, index := range indexes {
+		cols := mo.TupleToResult(db.Query(fmt.Sprintf("select name from pragma_index_info(%s) order by seqno", literal(index.A))))
+		if cols.IsError() {
+			return mo.Err[Entity](cols.Error())
+		}
+		var names []string
+		for cols.MustGet().Next() {
+			var name string
+			if err = cols.MustGet().Scan(&name); err != nil {
+				cols.MustGet().Close()
+				return mo.Err[Entity](err)
+			}
+			names = append(names, name)
+		}
+		cols.MustGet().Close()
+		// sqlite_autoindex_* of unique constraints are reserved by sqlite, they are named as the default unique index
+		name := lo.If(index.C == "u", fmt.Sprintf("uk_%s_%s", lo.SnakeCase(table), strings.Join(names, "_"))).Else(index.A)
+		for _, col := range names {
+			tags[col] = append(tags[col], fmt.Sprintf("%s=%s", lo.If(index.B, colUniq).Else(colIdx), name))
+		}
+	}
+	pks := lo.CountBy(columns, func(c column) bool {
+		return c.pk > 0
+	})
+	e := Entity{Name: lo.PascalCase(table), Table: table, Package: pkg}
+	for _, c := range columns {
+		typ := inverseType(mappings, c.typ)
+		if typ.IsAbsent() {
+			return mo.Err[Entity](fmt.Errorf("%s: can not find type mapping for %s %s", table, c.name, c.typ))
+		}
+		goType := string(typ.MustGet())
+		if !c.notNull && c.pk == 0 {
+			goType = lo.ValueOr(nullTypes, typ.MustGet(), fmt.Sprintf("*%s", goType))
+		}
+		col := c.name
+		// precision of string and decimal
+		if matches := preReg.FindStringSubmatch(c.typ); len(matches) > 1 && slices.Contains([]GoType{"string", "float64"}, typ.MustGet()) {
+			col = fmt.Sprintf("%s(%s)", col, strings.ReplaceAll(matches[1], " ", ""))
+		}
+		tag := []string{fmt.Sprintf("%s=%s", colName, col)}
+		if c.pk > 0 {
+			tag = append(tag, lo.If(pks > 1, fmt.Sprintf("%s=%d", colPK, c.pk)).Else(string(colPK)))
+		}
+		e.Fields = append(e.Fields, Field{A: lo.PascalCase(c.name), B: goType, C: strings.Join(append(tag, tags[c.name]...), ";")})
+	}
+	return mo.Ok(e)
+}
+
+// Import generate entities from all the tables of the sqlite database into dir, the package name
+// is the base name of dir
+func Import(db *sql.DB, dir string) mo.Result[[]Entity] {
+	rows, err := db.Query(fmt.Sprintf("select name from sqlite_master where type = 'table' and name not like 'sqlite_%%' and name != '%s' order by name", migrationTable))
+	if err != nil {
+		return mo.Err[[]Entity](err)
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			rows.Close()
+			return mo.Err[[]Entity](err)
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if len(tables) == 0 {
+		return mo.Err[[]Entity](fmt.Errorf("no tables found"))
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return mo.Err[[]Entity](err)
+	}
+	tmpl := mo.TupleToResult(template.New("entity").Parse(entityTmpl))
+	if tmpl.IsError() {
+		return mo.Err[[]Entity](tmpl.Error())
+	}
+	mappings := customTypes()
+	if mappings.IsError() {
+		return mo.Err[[]Entity](mappings.Error())
+	}
+	var entities []Entity
+	for _, table := range tables {
+		e := entity(db, table, filepath.Base(dir), mappings.MustGet())
+		if e.IsError() {
+			return mo.Err[[]Entity](e.Error())
+		}
+		var buf bytes.Buffer
+		if err = tmpl.MustGet().Execute(&buf, e.MustGet()); err != nil {
+			return mo.Err[[]Entity](err)
+		}
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return mo.Err[[]Entity](fmt.Errorf("%s: %w", table, err))
+		}
+		if err = os.WriteFile(filepath.Join(dir, fmt.Sprintf("%s.go", lo.SnakeCase(table))), src, 0644); err != nil {
+			return mo.Err[[]Entity](err)
+		}
+		entities = append(entities, e.MustGet())
+	}
+	return mo.Ok(entities)
+}
@@ -0,0 +1,78 @@
+package meta
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:importer.db?cache=shared&mode=memory")
+	assert.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`create table customer
+(
+    id    integer not null,
+    name  varchar(20) not null,
+    email text(50),
+    PRIMARY KEY (id)
+);
+create unique index uk_customer_email on customer (email);
+create table orders
+(
+    id          integer not null,
+    customer_id bigint  not null,
+    total       decimal(10, 2),
+    created_at  datetime not null,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_order_customer_id FOREIGN KEY (customer_id) REFERENCES customer (id) ON DELETE CASCADE
+);
+create table order_tag
+(
+    order_id integer not null,
+    tag      varchar(10) not null,
+    PRIMARY KEY (order_id, tag),
+    FOREIGN KEY (order_id) REFERENCES orders
+);
+create table product
+(
+    id    integer not null,
+    code  varchar(20) not null,
+    batch varchar(10) not null,
+    sku   varchar(20) not null unique,
+    PRIMARY KEY (id),
+    UNIQUE (code, batch)
+);`)
+	assert.NoError(t, err)
+	dir := filepath.Join(t.TempDir(), "entity")
+	entities := Import(db, dir).MustGet()
+	assert.Len(t, entities, 4)
+	assert.Equal(t, Entity{Name: "Customer", Table: "customer", Package: "entity", Fields: []Field{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Name", B: "string", C: "col=name(20)"},
+		{A: "Email", B: "sql.NullString", C: "col=email(50);uniq=uk_customer_email"},
+	}}, entities[0])
+	assert.Equal(t, []Field{
+		{A: "OrderId", B: "int64", C: "col=order_id;pk=1;ref=Orders.Id"},
+		{A: "Tag", B: "string", C: "col=tag(10);pk=2"},
+	}, entities[1].Fields)
+	assert.Equal(t, []Field{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "CustomerId", B: "int64", C: "col=customer_id;ref=Customer.Id;onDelete=cascade"},
+		{A: "Total", B: "sql.NullFloat64", C: "col=total(10,2)"},
+		{A: "CreatedAt", B: "time.Time", C: "col=created_at"},
+	}, entities[2].Fields)
+	assert.Equal(t, []string{"database/sql", "github.com/kcmvp/dbo/base", "time"}, entities[2].Imports())
+	// indexes of unique constraints are named as the default unique index rather than sqlite_autoindex_*
+	assert.Equal(t, []Field{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Code", B: "string", C: "col=code(20);uniq=uk_product_code_batch"},
+		{A: "Batch", B: "string", C: "col=batch(10);uniq=uk_product_code_batch"},
+		{A: "Sku", B: "string", C: "col=sku(20);uniq=uk_product_sku"},
+	}, entities[3].Fields)
+	src, err := os.ReadFile(filepath.Join(dir, "orders.go"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "func (Orders) Table() string {\n\treturn \"orders\"\n}")
+}
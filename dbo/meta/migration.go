@@ -0,0 +1,356 @@
+package meta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dominikbraun/graph"
+	"github.com/samber/lo"
+	"github.com/samber/mo"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const snapshotFile = "snapshot.json"
+
+type enumSnapshot struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Values []string `json:"values"`
+}
+
+type columnSnapshot struct {
+	Attr string        `json:"attr"`
+	Type string        `json:"type"`
+	Tag  string        `json:"tag"`
+	Enum *enumSnapshot `json:"enum,omitempty"`
+	Doc  string        `json:"doc,omitempty"`
+}
+
+type tableSnapshot struct {
+	Entity     string           `json:"entity"`
+	Name       string           `json:"name"`
+	Columns    []columnSnapshot `json:"columns"`
+	Doc        string           `json:"doc,omitempty"`
+	Datasource string           `json:"datasource,omitempty"`
+}
+
+// snapshot persisted model of the entities, it's the baseline of the next migration
+type snapshot struct {
+	Version int             `json:"version"`
+	Tables  []tableSnapshot `json:"tables"`
+}
+
+// dbo rebuild the model from the snapshot with the custom type mappings of the project
+func (s snapshot) dbo(mappings typeMappings) mo.Result[DBO] {
+	g := graph.New[string, Table](func(table Table) string {
+		return table.entity
+	}, graph.Directed())
+	for _, t := range s.Tables {
+		g.AddVertex(Table{entity: t.Entity, name: t.Name, doc: t.Doc, datasource: t.Datasource, columns: lo.Map(t.Columns, func(c columnSnapshot, _ int) Column {
+			column := Column{A: c.Attr, B: c.Type, C: c.Tag, doc: c.Doc, types: mappings}
+			if c.Enum != nil {
+				column.enum = mo.Some(Enum{name: c.Enum.Name, typ: GoType(c.Enum.Type), values: c.Enum.Values})
+			}
+			return column
+		})})
+	}
+	dbo := build(g)
+	if dbo.IsError() {
+		return dbo
+	}
+	return mo.Ok(DBO{g: dbo.MustGet().g, types: mappings})
+}
+
+func loadSnapshot(path string) mo.Result[snapshot] {
+	var s snapshot
+	data, err := os.ReadFile(filepath.Join(path, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return mo.Ok(s)
+	} else if err != nil {
+		return mo.Err[snapshot](err)
+	}
+	if err = json.Unmarshal(data, &s); err != nil {
+		return mo.Err[snapshot](fmt.Errorf("invalid snapshot %s: %w", snapshotFile, err))
+	}
+	return mo.Ok(s)
+}
+
+func (dbo DBO) snapshot(version int) snapshot {
+	// join tables are synthesized from the entities
+	tables := lo.Filter(dbo.Tables(), func(t Table, _ int) bool {
+		return !t.join
+	})
+	return snapshot{Version: version, Tables: lo.Map(tables, func(t Table, _ int) tableSnapshot {
+		return tableSnapshot{Entity: t.entity, Name: t.name, Doc: t.doc, Datasource: t.datasource, Columns: lo.Map(t.columns, func(c Column, _ int) columnSnapshot {
+			cs := columnSnapshot{Attr: c.A, Type: c.B, Tag: c.C, Doc: c.doc}
+			if e, ok := c.enum.Get(); ok {
+				cs.Enum = &enumSnapshot{Name: e.name, Type: string(e.typ), Values: e.values}
+			}
+			return cs
+		})}
+	})}
+}
+
+// Migration migration scripts of a datasource between the snapshot and the current model
+type Migration struct {
+	datasource string
+	version    int
+	up         map[string][]string
+	down       map[string][]string
+	warnings   []string
+}
+
+// Datasource name of the datasource, it's empty when no entity declares datasource
+func (m Migration) Datasource() string {
+	return m.datasource
+}
+
+// Version version of the migration
+func (m Migration) Version() int {
+	return m.version
+}
+
+// Warnings destructive or risky changes of the migration
+func (m Migration) Warnings() []string {
+	return m.warnings
+}
+
+// Empty identify there is no change between the snapshot and the current model
+func (m Migration) Empty() bool {
+	return lo.EveryBy(lo.Values(m.up), func(stmts []string) bool {
+		return len(stmts) == 0
+	})
+}
+
+// Diff generate migration scripts of every datasource by comparing the current model with the snapshot of the datasource.
+// Scripts are generated as <datasource>/<platform>/<version>_<name>.up.sql and <datasource>/<platform>/<version>_<name>.down.sql
+// for the platforms of the datasource, then the snapshot is upgraded to the new version. the datasource directory is
+// omitted when no entity declares datasource. Nothing is written for the datasource when there is no change.
+func (dbo DBO) Diff(path, name string) mo.Result[[]Migration] {
+	var migrations []Migration
+	err := dbo.each(path, func(ds string, dbo DBO, path string) error {
+		platforms := platformsOf(ds)
+		if platforms.IsError() {
+			return platforms.Error()
+		}
+		m := dbo.migration(path, name, platforms.MustGet())
+		if m.IsError() {
+			return m.Error()
+		}
+		migration := m.MustGet()
+		migration.datasource = ds
+		migrations = append(migrations, migration)
+		return nil
+	})
+	return lo.If(err != nil, mo.Err[[]Migration](err)).Else(mo.Ok(migrations))
+}
+
+// migration generate migration scripts of the platforms by comparing the model with the snapshot in the path
+func (dbo DBO) migration(path, name string, platforms []string) mo.Result[Migration] {
+	s := loadSnapshot(path)
+	if s.IsError() {
+		return mo.Err[Migration](s.Error())
+	}
+	previous := s.MustGet().dbo(dbo.types)
+	if previous.IsError() {
+		return mo.Err[Migration](fmt.Errorf("invalid snapshot %s: %w", snapshotFile, previous.Error()))
+	}
+	m := Migration{version: s.MustGet().Version + 1, up: map[string][]string{}, down: map[string][]string{}}
+	for _, platform := range platforms {
+		up := diff(previous.MustGet(), dbo, platform)
+		if up.IsError() {
+			return mo.Err[Migration](up.Error())
+		}
+		down := diff(dbo, previous.MustGet(), platform)
+		if down.IsError() {
+			return mo.Err[Migration](down.Error())
+		}
+		m.up[platform], m.down[platform] = up.MustGet().A, down.MustGet().A
+		m.warnings = lo.Uniq(append(m.warnings, up.MustGet().B...))
+	}
+	if m.Empty() {
+		return mo.Ok(m)
+	}
+	for platform := range m.up {
+		dir := filepath.Join(path, platform)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return mo.Err[Migration](err)
+		}
+		for suffix, stmts := range map[string][]string{"up": m.up[platform], "down": m.down[platform]} {
+			file := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", m.version, lo.SnakeCase(name), suffix))
+			if err := os.WriteFile(file, []byte(strings.Join(stmts, "\n")+"\n"), 0644); err != nil {
+				return mo.Err[Migration](err)
+			}
+		}
+	}
+	data, _ := json.MarshalIndent(dbo.snapshot(m.version), "", "  ")
+	if err := os.WriteFile(filepath.Join(path, snapshotFile), data, 0644); err != nil {
+		return mo.Err[Migration](err)
+	}
+	return mo.Ok(m)
+}
+
+// diff generate migration statements and warnings of the platform to migrate model from to model to
+func diff(from, to DBO, db string) mo.Result[lo.Tuple2[[]string, []string]] {
+	var stmts, warnings []string
+	tmpl := to.schema(db)
+	if tmpl.IsError() {
+		return mo.Err[lo.Tuple2[[]string, []string]](tmpl.Error())
+	}
+	d := DialectOf(db).MustGet()
+	fromTables := lo.SliceToMap(from.Tables(), func(t Table) (string, Table) {
+		return t.entity, t
+	})
+	toTables := lo.SliceToMap(to.Tables(), func(t Table) (string, Table) {
+		return t.entity, t
+	})
+	kept := lo.Filter(to.Tables(), func(t Table, _ int) bool {
+		_, ok := fromTables[t.entity]
+		return ok
+	})
+	// drop removed or changed constraints before altering tables
+	for _, tt := range kept {
+		ft := fromTables[tt.entity]
+		fks := to.ForeignKeys(tt.entity)
+		for _, fk := range from.ForeignKeys(ft.entity) {
+			if !slices.Contains(fks, fk) {
+				stmts = append(stmts, d.DropForeignKey(d.Ident(ft.Name()), fk))
+			}
+		}
+		indexes := tt.Indexes()
+		for _, idx := range ft.Indexes() {
+			if !lo.ContainsBy(indexes, idx.equal) {
+				stmts = append(stmts, d.DropIndex(d.Ident(ft.Name()), idx))
+			}
+		}
+	}
+	for _, tt := range kept {
+		if ft := fromTables[tt.entity]; ft.Name() != tt.Name() {
+			stmts = append(stmts, d.RenameTable(ft.Name(), tt.Name()))
+		}
+		if ft := fromTables[tt.entity]; ft.doc != tt.doc {
+			stmts = append(stmts, d.CommentOn(tt, mo.None[Column]())...)
+		}
+	}
+	// native enums are created before tables and dropped after tables
+	fromEnums := lo.SliceToMap(from.nativeEnums(d), func(e Enum) (string, Enum) {
+		return e.Name(), e
+	})
+	toEnums := lo.SliceToMap(to.nativeEnums(d), func(e Enum) (string, Enum) {
+		return e.Name(), e
+	})
+	for _, e := range to.nativeEnums(d) {
+		if fe, ok := fromEnums[e.Name()]; !ok {
+			stmts = append(stmts, d.CreateEnum(e))
+		} else if !fe.equal(e) {
+			if removed := lo.Without(fe.values, e.values...); len(removed) > 0 {
+				warnings = append(warnings, fmt.Sprintf("values %s of enum %s are removed", strings.Join(removed, ", "), e.Name()))
+				stmts = append(stmts, fmt.Sprintf("-- values %s of enum %s are removed, please migrate it manually", strings.Join(removed, ", "), e.Name()))
+			}
+			stmts = append(stmts, d.AlterEnum(fe, e)...)
+		}
+	}
+	for _, tt := range to.Tables() {
+		if _, ok := fromTables[tt.entity]; !ok {
+			var sb strings.Builder
+			if err := tmpl.MustGet().ExecuteTemplate(&sb, "table", tt); err != nil {
+				return mo.Err[lo.Tuple2[[]string, []string]](err)
+			}
+			stmts = append(stmts, strings.TrimSpace(sb.String()))
+		}
+	}
+	for _, tt := range kept {
+		ft := fromTables[tt.entity]
+		if ft.PK() != tt.PK() {
+			warnings = append(warnings, fmt.Sprintf("primary key of %s is changed from (%s) to (%s)", tt.Name(), ft.PK(), tt.PK()))
+			stmts = append(stmts, fmt.Sprintf("-- primary key of %s is changed from (%s) to (%s), please migrate it manually", tt.Name(), ft.PK(), tt.PK()))
+		}
+		for _, c := range tt.Columns() {
+			fc := mo.TupleToOption(lo.Find(ft.Columns(), func(item Column) bool {
+				return item.Name() == c.Name()
+			}))
+			if fc.IsAbsent() {
+				if !c.Nullable() {
+					warnings = append(warnings, fmt.Sprintf("add not null column %s.%s, it fails on non empty table", tt.Name(), c.Name()))
+				}
+				stmts = append(stmts, d.AddColumn(d.Ident(tt.Name()), d.Ident(c.Name()), tt.ColumnDef(c, db)))
+			} else if fc.MustGet().Type(db) != c.Type(db) || fc.MustGet().Nullable() != c.Nullable() || fc.MustGet().onUpdate(d) != c.onUpdate(d) {
+				if fc.MustGet().Type(db) != c.Type(db) {
+					warnings = append(warnings, fmt.Sprintf("type of %s.%s is changed from %s to %s", tt.Name(), c.Name(), fc.MustGet().Type(db), c.Type(db)))
+				}
+				if fc.MustGet().Nullable() && !c.Nullable() {
+					warnings = append(warnings, fmt.Sprintf("%s.%s is changed to not null", tt.Name(), c.Name()))
+				}
+				stmts = append(stmts, d.AlterColumn(tt, fc.MustGet(), c)...)
+			}
+			if fc.IsPresent() && fc.MustGet().Default(db) != c.Default(db) {
+				stmts = append(stmts, d.AlterDefault(d.Ident(tt.Name()), c))
+			}
+			if aut := c.Property(colAut).IsPresent(); fc.IsPresent() && fc.MustGet().Property(colAut).IsPresent() != aut {
+				stmts = append(stmts, lo.If(aut, d.Trigger(tt, c)).Else(d.DropTrigger(tt, fc.MustGet()))...)
+			} else if fc.IsAbsent() && aut {
+				stmts = append(stmts, d.Trigger(tt, c)...)
+			}
+			if fc.IsPresent() && fc.MustGet().doc != c.doc {
+				for _, stmt := range d.CommentOn(tt, mo.Some(c)) {
+					if !slices.Contains(stmts, stmt) {
+						stmts = append(stmts, stmt)
+					}
+				}
+			}
+			if check := c.check(d); fc.IsPresent() && fc.MustGet().check(d) != check {
+				warnings = append(warnings, fmt.Sprintf("check constraint of %s.%s is changed", tt.Name(), c.Name()))
+				stmts = append(stmts, fmt.Sprintf("-- check constraint of %s.%s is changed to '%s', please migrate it manually", tt.Name(), c.Name(), check))
+			}
+		}
+		for _, fc := range ft.Columns() {
+			if !lo.ContainsBy(tt.Columns(), func(c Column) bool {
+				return c.Name() == fc.Name()
+			}) {
+				warnings = append(warnings, fmt.Sprintf("drop column %s.%s", tt.Name(), fc.Name()))
+				stmts = append(stmts, fmt.Sprintf("alter table %s drop column %s;", d.Ident(tt.Name()), d.Ident(fc.Name())))
+			}
+		}
+	}
+	// create new or changed constraints after altering tables
+	for _, tt := range kept {
+		ft := fromTables[tt.entity]
+		indexes := ft.Indexes()
+		for _, idx := range tt.Indexes() {
+			if !lo.ContainsBy(indexes, idx.equal) {
+				stmts = append(stmts, fmt.Sprintf("create %sindex %s on %s (%s);", lo.If(idx.Unique(), "unique ").Else(""), d.Ident(idx.Name()), d.Ident(tt.Name()), idents(d, idx.columns)))
+			}
+		}
+		fks := from.ForeignKeys(ft.entity)
+		for _, fk := range to.ForeignKeys(tt.entity) {
+			if !slices.Contains(fks, fk) {
+				stmts = append(stmts, d.AddForeignKey(d.Ident(tt.Name()), fk))
+			}
+		}
+	}
+	for _, ft := range lo.Reverse(from.Tables()) {
+		if _, ok := toTables[ft.entity]; !ok {
+			warnings = append(warnings, fmt.Sprintf("drop table %s", ft.Name()))
+			// triggers maintaining aut columns are dropped first, the trigger function of pg is not dropped with the table
+			for _, c := range ft.Columns() {
+				if c.Property(colAut).IsPresent() {
+					stmts = append(stmts, d.DropTrigger(ft, c)...)
+				}
+			}
+			stmts = append(stmts, fmt.Sprintf("drop table %s;", d.Ident(ft.Name())))
+		}
+	}
+	for _, e := range from.nativeEnums(d) {
+		if _, ok := toEnums[e.Name()]; !ok {
+			stmts = append(stmts, d.DropEnum(e))
+		}
+	}
+	return mo.Ok(lo.T2(stmts, warnings))
+}
+
+func (idx Index) equal(other Index) bool {
+	return idx.Name() == other.Name() && idx.Unique() == other.Unique() && idx.Columns() == other.Columns()
+}
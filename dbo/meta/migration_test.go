@@ -0,0 +1,166 @@
+package meta
+
+import (
+	"github.com/samber/mo"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	from := newDBO(
+		Table{entity: "Customer", name: "customer", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Name", B: "string", C: "col=name(20)"},
+			{A: "Phone", B: "string", C: "col=phone(10)"},
+		}},
+		Table{entity: "Legacy", name: "legacy", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "UpdatedAt", B: "time.Time", C: "col=updated_at;aut"},
+		}},
+	).MustGet()
+	to := newDBO(
+		Table{entity: "Customer", name: "customer", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Name", B: "string", C: "col=name(50);idx"},
+			{A: "Email", B: "*string", C: "col=email(50)"},
+		}},
+		Table{entity: "Order", name: "orders", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "CustomerId", B: "int64", C: "col=customer_id;ref=Customer.Id"},
+		}},
+	).MustGet()
+	up := diff(from, to, "pg").MustGet()
+	assert.Equal(t, []string{
+		"create table orders\n(\n    id          bigint not null generated always as identity,\n    customer_id bigint not null,\n    PRIMARY KEY (id),\n    CONSTRAINT fk_order_customer_id FOREIGN KEY (customer_id) REFERENCES customer (id)\n);",
+		"alter table customer alter column name type varchar(50);",
+		"alter table customer add column email varchar(50);",
+		"alter table customer drop column phone;",
+		"create index idx_customer_name on customer (name);",
+		"drop trigger trg_legacy_updated_at on legacy;",
+		"drop function trg_legacy_updated_at();",
+		"drop table legacy;",
+	}, up.A)
+	assert.Equal(t, []string{
+		"type of customer.name is changed from varchar(20) to varchar(50)",
+		"drop column customer.phone",
+		"drop table legacy",
+	}, up.B)
+	down := diff(to, from, "mysql").MustGet()
+	assert.Equal(t, []string{
+		"drop index idx_customer_name on customer;",
+		"create table legacy\n(\n    id         bigint not null auto_increment,\n    updated_at timestamp not null default current_timestamp on update current_timestamp,\n    PRIMARY KEY (id)\n);",
+		"alter table customer modify column name varchar(20) not null;",
+		"alter table customer add column phone varchar(10) not null;",
+		"alter table customer drop column email;",
+		"drop table orders;",
+	}, down.A)
+	assert.Empty(t, diff(to, to, "sqlite").MustGet().A)
+}
+
+func TestDiffEnum(t *testing.T) {
+	status := Enum{name: "OrderStatus", typ: "string", values: []string{"new", "paid"}}
+	from := newDBO(Table{entity: "Order", name: "orders", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Status", B: "entity.OrderStatus", C: "col=status", enum: mo.Some(status)},
+	}}).MustGet()
+	status.values = []string{"new", "shipped"}
+	to := newDBO(Table{entity: "Order", name: "orders", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Status", B: "entity.OrderStatus", C: "col=status", enum: mo.Some(status)},
+	}}).MustGet()
+	up := diff(from, to, "pg").MustGet()
+	assert.Equal(t, []string{
+		"-- values paid of enum order_status are removed, please migrate it manually",
+		"alter type order_status add value 'shipped';",
+	}, up.A)
+	assert.Equal(t, []string{"values paid of enum order_status are removed"}, up.B)
+	up = diff(from, to, "mysql").MustGet()
+	assert.Equal(t, []string{
+		"-- check constraint of orders.status is changed to 'check (status in ('new', 'shipped'))', please migrate it manually",
+	}, up.A)
+	restored := to.snapshot(1).dbo(nil).MustGet()
+	assert.Equal(t, status, restored.Table("Order").Column("Status").MustGet().Enum().MustGet())
+	empty := newDBO().MustGet()
+	assert.Equal(t, "create type order_status as enum ('new', 'shipped');", diff(empty, to, "pg").MustGet().A[0])
+	assert.Equal(t, []string{"drop table orders;", "drop type order_status;"}, diff(to, empty, "pg").MustGet().A)
+}
+
+func TestDiffDefault(t *testing.T) {
+	from := newDBO(Table{entity: "Product", name: "product", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Stock", B: "int32", C: "col=stock;default=0"},
+	}}).MustGet()
+	to := newDBO(Table{entity: "Product", name: "product", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Stock", B: "int32", C: "col=stock;check=stock >= 0"},
+	}}).MustGet()
+	assert.Equal(t, []string{
+		"alter table product alter column stock drop default;",
+		"-- check constraint of product.stock is changed to 'check (stock >= 0)', please migrate it manually",
+	}, diff(from, to, "pg").MustGet().A)
+	assert.Equal(t, []string{"alter table product add default 0 for stock;"}, diff(to, from, "sqlserver").MustGet().A[:1])
+}
+
+func TestDiffAutoUpdate(t *testing.T) {
+	from := newDBO(Table{entity: "Product", name: "product", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "UpdatedAt", B: "time.Time", C: "col=updated_at"},
+	}}).MustGet()
+	to := newDBO(Table{entity: "Product", name: "product", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "UpdatedAt", B: "time.Time", C: "col=updated_at;aut"},
+	}}).MustGet()
+	assert.Equal(t, []string{
+		"alter table product modify column updated_at timestamp not null default current_timestamp on update current_timestamp;",
+		"alter table product alter column updated_at set default current_timestamp;",
+	}, diff(from, to, "mysql").MustGet().A)
+	sqlite := diff(from, to, "sqlite").MustGet().A
+	assert.Len(t, sqlite, 2)
+	assert.Equal(t, "-- sqlite does not support altering default value of column updated_at, table product needs to be rebuilt", sqlite[0])
+	assert.Contains(t, sqlite[1], "create trigger trg_product_updated_at after update on product")
+	assert.Equal(t, "drop trigger trg_product_updated_at;", diff(to, from, "sqlite").MustGet().A[1])
+	assert.Equal(t, []string{
+		"alter table product alter column updated_at drop default;",
+		"drop trigger trg_product_updated_at on product;",
+		"drop function trg_product_updated_at();",
+	}, diff(to, from, "pg").MustGet().A)
+}
+
+func TestDiffComment(t *testing.T) {
+	from := newDBO(Table{entity: "Product", name: "product", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Name", B: "string", C: "col=name"},
+	}}).MustGet()
+	to := newDBO(Table{entity: "Product", name: "product", doc: "goods", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+		{A: "Name", B: "string", C: "col=name", doc: "product name"},
+	}}).MustGet()
+	assert.Equal(t, []string{
+		"comment on table product is 'goods';",
+		"comment on column product.name is 'product name';",
+	}, diff(from, to, "pg").MustGet().A)
+	assert.Equal(t, []string{
+		"alter table product comment 'goods';",
+		"alter table product modify column name varchar(25) not null comment 'product name';",
+	}, diff(from, to, "mysql").MustGet().A)
+	assert.Empty(t, diff(from, to, "sqlite").MustGet().A)
+}
+
+func TestMigration(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ds1")
+	dbo := newDBO(Table{entity: "Product", name: "product", datasource: "ds1", columns: []Column{
+		{A: "Id", B: "int64", C: "col=id;pk"},
+	}}).MustGet()
+	m := dbo.migration(dir, "init", []string{"pg", "sqlite"}).MustGet()
+	assert.Equal(t, 1, m.Version())
+	assert.False(t, m.Empty())
+	for _, platform := range []string{"pg", "sqlite"} {
+		assert.FileExists(t, filepath.Join(dir, platform, "0001_init.up.sql"))
+		assert.FileExists(t, filepath.Join(dir, platform, "0001_init.down.sql"))
+	}
+	assert.NoDirExists(t, filepath.Join(dir, "mysql"))
+	assert.Equal(t, 1, loadSnapshot(dir).MustGet().Version)
+	assert.True(t, dbo.migration(dir, "again", []string{"pg", "sqlite"}).MustGet().Empty())
+	assert.NoFileExists(t, filepath.Join(dir, "pg", "0002_again.up.sql"))
+}
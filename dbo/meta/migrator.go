@@ -0,0 +1,215 @@
+package meta
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/samber/lo"
+	"github.com/samber/mo"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const migrationTable = "dbo_migration"
+
+var migrationReg = regexp.MustCompile(`^(\d+)_(.+)\.up\.sql$`)
+
+// MigrationStatus status of a migration script
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt mo.Option[time.Time]
+	file      string
+}
+
+// Migrator apply versioned migration scripts of the platform to a database,
+// applied versions are recorded in table dbo_migration
+type Migrator struct {
+	db      *sql.DB
+	dialect Dialect
+	dir     string
+}
+
+// NewMigrator create a migrator of the datasource with scripts in path/<datasource>/<platform>. scripts are in
+// path/<platform> for the datasource without name, or when no entity declares datasource
+func NewMigrator(db *sql.DB, ds Datasource, path string) mo.Result[Migrator] {
+	d := DialectOf(ds.DB)
+	if d.IsAbsent() {
+		return mo.Err[Migrator](fmt.Errorf("unsupported platform %s", ds.DB))
+	}
+	if dir := filepath.Join(path, ds.Name); len(ds.Name) > 0 {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			path = dir
+		}
+	}
+	return mo.Ok(Migrator{db: db, dialect: d.MustGet(), dir: filepath.Join(path, ds.DB)})
+}
+
+// bind return the bind variable of the platform
+func (m Migrator) bind(i int) string {
+	return m.dialect.Bind(i)
+}
+
+// init create the migration table when it does not exist, existence is checked by querying the table
+// as 'create table if not exists' is not supported by all the platforms
+func (m Migrator) init() error {
+	if _, err := m.db.Exec(fmt.Sprintf("select count(*) from %s", migrationTable)); err == nil {
+		return nil
+	}
+	_, err := m.db.Exec(fmt.Sprintf(`create table %s
+(
+    version    integer      not null,
+    name       varchar(255) not null,
+    applied_at %s not null,
+    PRIMARY KEY (version)
+)`, migrationTable, lo.CoalesceOrEmpty(m.dialect.Types()["time.Time"], "timestamp")))
+	return err
+}
+
+// scripts return all the migration scripts in version order
+func (m Migrator) scripts() mo.Result[[]MigrationStatus] {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return mo.Err[[]MigrationStatus](err)
+	}
+	var scripts []MigrationStatus
+	for _, entry := range entries {
+		if matches := migrationReg.FindStringSubmatch(entry.Name()); len(matches) > 0 {
+			version, _ := strconv.Atoi(matches[1])
+			if lo.ContainsBy(scripts, func(s MigrationStatus) bool {
+				return s.Version == version
+			}) {
+				return mo.Err[[]MigrationStatus](fmt.Errorf("duplicated migration version %d", version))
+			}
+			scripts = append(scripts, MigrationStatus{Version: version, Name: matches[2], file: strings.TrimSuffix(entry.Name(), ".up.sql")})
+		}
+	}
+	slices.SortFunc(scripts, func(a, b MigrationStatus) int {
+		return a.Version - b.Version
+	})
+	return mo.Ok(scripts)
+}
+
+// Status return all migrations with applied time
+func (m Migrator) Status() mo.Result[[]MigrationStatus] {
+	if err := m.init(); err != nil {
+		return mo.Err[[]MigrationStatus](err)
+	}
+	scripts := m.scripts()
+	if scripts.IsError() {
+		return scripts
+	}
+	rows, err := m.db.Query(fmt.Sprintf("select version, applied_at from %s", migrationTable))
+	if err != nil {
+		return mo.Err[[]MigrationStatus](err)
+	}
+	defer rows.Close()
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at any
+		if err = rows.Scan(&version, &at); err != nil {
+			return mo.Err[[]MigrationStatus](err)
+		}
+		// mysql returns timestamp as text without parseTime=true
+		switch v := at.(type) {
+		case time.Time:
+			applied[version] = v
+		case []byte:
+			applied[version], _ = time.Parse(time.DateTime, string(v))
+		case string:
+			applied[version], _ = time.Parse(time.DateTime, v)
+		}
+	}
+	return mo.Ok(lo.Map(scripts.MustGet(), func(s MigrationStatus, _ int) MigrationStatus {
+		if at, ok := applied[s.Version]; ok {
+			s.AppliedAt = mo.Some(at)
+		}
+		return s
+	}))
+}
+
+// exec execute the script and record or remove the version in one transaction
+func (m Migrator) exec(s MigrationStatus, up bool) error {
+	script, err := os.ReadFile(filepath.Join(m.dir, fmt.Sprintf("%s.%s.sql", s.file, lo.If(up, "up").Else("down"))))
+	if err != nil {
+		return err
+	}
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(string(script)); err == nil {
+		if up {
+			_, err = tx.Exec(fmt.Sprintf("insert into %s (version, name, applied_at) values (%s, %s, %s)", migrationTable, m.bind(1), m.bind(2), m.bind(3)),
+				s.Version, s.Name, time.Now())
+		} else {
+			_, err = tx.Exec(fmt.Sprintf("delete from %s where version = %s", migrationTable, m.bind(1)), s.Version)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration %s: %w", s.file, err)
+	}
+	return tx.Commit()
+}
+
+// Up apply all pending migrations in version order, return applied migrations
+func (m Migrator) Up() mo.Result[[]MigrationStatus] {
+	status := m.Status()
+	if status.IsError() {
+		return status
+	}
+	var applied []MigrationStatus
+	for _, s := range status.MustGet() {
+		if s.AppliedAt.IsPresent() {
+			continue
+		}
+		if err := m.exec(s, true); err != nil {
+			return mo.Err[[]MigrationStatus](err)
+		}
+		applied = append(applied, s)
+	}
+	return mo.Ok(applied)
+}
+
+// Down revert last n applied migrations, return reverted migrations
+func (m Migrator) Down(n int) mo.Result[[]MigrationStatus] {
+	status := m.Status()
+	if status.IsError() {
+		return status
+	}
+	var reverted []MigrationStatus
+	for _, s := range lo.Reverse(status.MustGet()) {
+		if len(reverted) == n {
+			break
+		}
+		if s.AppliedAt.IsAbsent() {
+			continue
+		}
+		if err := m.exec(s, false); err != nil {
+			return mo.Err[[]MigrationStatus](err)
+		}
+		reverted = append(reverted, s)
+	}
+	return mo.Ok(reverted)
+}
+
+// Scripts execute the scripts in order, they are executed every time and should be idempotent
+// for persistent database
+func (m Migrator) Scripts(files ...string) error {
+	for _, file := range files {
+		script, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err = m.db.Exec(string(script)); err != nil {
+			return fmt.Errorf("script %s: %w", filepath.Base(file), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package meta
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrator(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sqlite"), 0755)
+	scripts := map[string]string{
+		"0001_init.up.sql":       "create table customer (id integer not null, PRIMARY KEY (id));",
+		"0001_init.down.sql":     "drop table customer;",
+		"0002_address.up.sql":    "create table address (id integer not null, PRIMARY KEY (id));\nalter table customer add column name text(20);",
+		"0002_address.down.sql":  "alter table customer drop column name;\ndrop table address;",
+		"0003_invalid.down.sql":  "drop table unknown;",
+		"0003_invalid.readme.md": "not a migration",
+	}
+	for name, script := range scripts {
+		os.WriteFile(filepath.Join(dir, "sqlite", name), []byte(script), 0644)
+	}
+	db, err := sql.Open("sqlite3", "file:migrator.db?cache=shared&mode=memory")
+	assert.NoError(t, err)
+	defer db.Close()
+	m := NewMigrator(db, Datasource{Name: "ds1", DB: "sqlite"}, dir).MustGet()
+	status := m.Status().MustGet()
+	assert.Len(t, status, 2)
+	assert.True(t, status[0].AppliedAt.IsAbsent())
+	applied := m.Up().MustGet()
+	assert.Len(t, applied, 2)
+	assert.Equal(t, "address", applied[1].Name)
+	assert.Empty(t, m.Up().MustGet())
+	status = m.Status().MustGet()
+	assert.True(t, status[0].AppliedAt.IsPresent())
+	assert.True(t, status[1].AppliedAt.IsPresent())
+	_, err = db.Exec("insert into customer (id, name) values (1, 'abc')")
+	assert.NoError(t, err)
+	reverted := m.Down(1).MustGet()
+	assert.Len(t, reverted, 1)
+	assert.Equal(t, 2, reverted[0].Version)
+	status = m.Status().MustGet()
+	assert.True(t, status[1].AppliedAt.IsAbsent())
+	assert.Error(t, m.Scripts(filepath.Join(dir, "sqlite", "0003_invalid.down.sql")))
+	assert.NoError(t, m.Scripts(filepath.Join(dir, "sqlite", "0002_address.up.sql")))
+	trigger := filepath.Join(dir, "trigger.sql")
+	os.WriteFile(trigger, []byte(`create trigger trg_customer_name after update on customer
+begin
+    update customer set name = upper(new.name) where id = new.id and name <> upper(new.name);
+end;
+insert into address (id) values (1);`), 0644)
+	assert.NoError(t, m.Scripts(trigger))
+	var count int
+	assert.NoError(t, db.QueryRow("select count(*) from address").Scan(&count))
+	assert.Equal(t, 1, count)
+	os.MkdirAll(filepath.Join(dir, "ds2", "sqlite"), 0755)
+	assert.Equal(t, filepath.Join(dir, "ds2", "sqlite"), NewMigrator(db, Datasource{Name: "ds2", DB: "sqlite"}, dir).MustGet().dir)
+}
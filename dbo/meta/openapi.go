@@ -0,0 +1,143 @@
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kcmvp/app"
+	"github.com/samber/lo"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+	openAPIVersion  = "3.0.3"
+)
+
+// JSONSchema json schema of an entity or an attribute. nullable attribute is marked by `nullable` in OpenAPI 3.0,
+// and its type is a union with null in json schema draft-07
+type JSONSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	ID          string                 `json:"$id,omitempty"`
+	Ref         string                 `json:"$ref,omitempty"`
+	AllOf       []*JSONSchema          `json:"allOf,omitempty"`
+	AnyOf       []*JSONSchema          `json:"anyOf,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        any                    `json:"type,omitempty"`
+	Format      string                 `json:"format,omitempty"`
+	Nullable    bool                   `json:"nullable,omitempty"`
+	ReadOnly    bool                   `json:"readOnly,omitempty"`
+	MaxLength   int                    `json:"maxLength,omitempty"`
+	Minimum     *int                   `json:"minimum,omitempty"`
+	Enum        []any                  `json:"enum,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+}
+
+// jsonType json type and format of the go type
+func jsonType(typ GoType) (string, string) {
+	switch {
+	case typ == "bool":
+		return "boolean", ""
+	case typ == "time.Time":
+		return "string", "date-time"
+	case typ == "[]byte":
+		return "string", "byte"
+	case typ == "github.com/shopspring/decimal.Decimal":
+		// decimal is marshalled as string to keep the precision
+		return "string", "decimal"
+	case typ == "float32":
+		return "number", "float"
+	case slices.Contains(floatTypes, typ):
+		return "number", "double"
+	case slices.Contains([]GoType{"int64", "uint64", "int", "uint", "time.Duration"}, typ):
+		return "integer", "int64"
+	case slices.Contains(intTypes, typ), slices.Contains(uintTypes, typ):
+		return "integer", "int32"
+	default:
+		return "string", ""
+	}
+}
+
+// property json schema of the column. reference column refers to the referenced attribute, and precision of
+// string column is the max length. nullable is marked by `nullable` for OpenAPI, otherwise by null type
+func (t Table) property(c Column, ref func(entity, attr string) string, openapi bool) *JSONSchema {
+	p := &JSONSchema{Description: c.doc, Nullable: openapi && c.Nullable(), ReadOnly: c.Managed() || t.Identity(c)}
+	if r := c.Ref(); r.IsPresent() {
+		referred := strings.Split(r.MustGet(), ".")
+		// siblings of $ref are ignored, the reference is wrapped by allOf, or anyOf with null type
+		if c.Nullable() && !openapi {
+			p.AnyOf = []*JSONSchema{{Ref: ref(referred[0], referred[1])}, {Type: "null"}}
+		} else {
+			p.AllOf = []*JSONSchema{{Ref: ref(referred[0], referred[1])}}
+		}
+		return p
+	}
+	typ, format := jsonType(c.GoType())
+	p.Type, p.Format = lo.If[any](c.Nullable() && !openapi, []string{typ, "null"}).Else(typ), format
+	if slices.Contains(uintTypes, c.GoType()) {
+		p.Minimum = lo.ToPtr(0)
+	}
+	if e := c.Enum(); e.IsPresent() {
+		p.Enum = lo.Map(e.MustGet().values, func(v string, _ int) any {
+			return lo.If[any](typ == "string", v).Else(json.Number(v))
+		})
+	}
+	if precision := c.precision(); typ == "string" && len(format) == 0 && len(precision) > 0 {
+		p.MaxLength = precision[0]
+	}
+	return p
+}
+
+// jsonSchema json schema of the entity, attributes are required unless they are nullable
+func (t Table) jsonSchema(ref func(entity, attr string) string, openapi bool) *JSONSchema {
+	s := &JSONSchema{Title: t.entity, Description: t.doc, Type: "object", Properties: map[string]*JSONSchema{}}
+	for _, c := range t.columns {
+		s.Properties[c.A] = t.property(c, ref, openapi)
+		if !c.Nullable() {
+			s.Required = append(s.Required, c.A)
+		}
+	}
+	return s
+}
+
+// JSONSchema generate json schema of every entity in path/schema, and OpenAPI document path/openapi.json
+// with all the entities in components/schemas. join tables are not entities, they are skipped
+func (dbo DBO) JSONSchema(path string) error {
+	dir := filepath.Join(path, "schema")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	write := func(file string, v any) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(file, data, 0644)
+	}
+	components := map[string]*JSONSchema{}
+	for _, t := range dbo.Tables() {
+		if t.join {
+			continue
+		}
+		s := t.jsonSchema(func(entity, attr string) string {
+			return fmt.Sprintf("%s.json#/properties/%s", entity, attr)
+		}, false)
+		s.Schema, s.ID = jsonSchemaDraft, fmt.Sprintf("%s.json", t.entity)
+		if err := write(filepath.Join(dir, s.ID), s); err != nil {
+			return err
+		}
+		components[t.entity] = t.jsonSchema(func(entity, attr string) string {
+			return fmt.Sprintf("#/components/schemas/%s/properties/%s", entity, attr)
+		}, true)
+	}
+	return write(filepath.Join(path, "openapi.json"), map[string]any{
+		"openapi":    openAPIVersion,
+		"info":       map[string]string{"title": filepath.Base(app.RootDir()), "version": "1.0.0"},
+		"paths":      map[string]any{},
+		"components": map[string]any{"schemas": components},
+	})
+}
@@ -0,0 +1,61 @@
+package meta
+
+import (
+	"encoding/json"
+	"github.com/samber/lo"
+	"github.com/samber/mo"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDBO_JSONSchema(t *testing.T) {
+	status := Enum{name: "OrderStatus", typ: "string", values: []string{"created", "paid"}}
+	dbo := newDBO(
+		Table{entity: "Customer", name: "customer", doc: "customer of the shop", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "Name", B: "string", C: "col=name(20)", doc: "full name"},
+			{A: "Age", B: "*uint8", C: "col=age"},
+		}},
+		Table{entity: "Order", name: "orders", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "CustomerId", B: "int64", C: "col=customer_id;ref=Customer.Id"},
+			{A: "ReferrerId", B: "*int64", C: "col=referrer_id;ref=Customer.Id"},
+			{A: "Status", B: "entity.OrderStatus", C: "col=status(10)", enum: mo.Some(status)},
+			{A: "Amount", B: "github.com/shopspring/decimal.Decimal", C: "col=amount(10,2)"},
+			{A: "CreatedAt", B: "time.Time", C: "col=created_at;act"},
+		}},
+	).MustGet()
+	dir := t.TempDir()
+	assert.NoError(t, dbo.JSONSchema(dir))
+	var customer JSONSchema
+	assert.NoError(t, json.Unmarshal(lo.Must(os.ReadFile(filepath.Join(dir, "schema", "Customer.json"))), &customer))
+	assert.Equal(t, "Customer.json", customer.ID)
+	assert.Equal(t, "customer of the shop", customer.Description)
+	assert.Equal(t, []string{"Id", "Name"}, customer.Required)
+	assert.Equal(t, &JSONSchema{Type: "integer", Format: "int64", ReadOnly: true}, customer.Properties["Id"])
+	assert.Equal(t, &JSONSchema{Type: "string", MaxLength: 20, Description: "full name"}, customer.Properties["Name"])
+	assert.Equal(t, &JSONSchema{Type: []any{"integer", "null"}, Format: "int32", Minimum: lo.ToPtr(0)}, customer.Properties["Age"])
+	var order JSONSchema
+	assert.NoError(t, json.Unmarshal(lo.Must(os.ReadFile(filepath.Join(dir, "schema", "Order.json"))), &order))
+	assert.Equal(t, "Customer.json#/properties/Id", order.Properties["CustomerId"].AllOf[0].Ref)
+	assert.Equal(t, []*JSONSchema{{Ref: "Customer.json#/properties/Id"}, {Type: "null"}}, order.Properties["ReferrerId"].AnyOf)
+	assert.Equal(t, []any{"created", "paid"}, order.Properties["Status"].Enum)
+	assert.Equal(t, &JSONSchema{Type: "string", Format: "decimal"}, order.Properties["Amount"])
+	assert.Equal(t, &JSONSchema{Type: "string", Format: "date-time", ReadOnly: true}, order.Properties["CreatedAt"])
+	var openapi struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]*JSONSchema `json:"schemas"`
+		} `json:"components"`
+	}
+	assert.NoError(t, json.Unmarshal(lo.Must(os.ReadFile(filepath.Join(dir, "openapi.json"))), &openapi))
+	assert.Equal(t, "3.0.3", openapi.OpenAPI)
+	assert.ElementsMatch(t, []string{"Customer", "Order"}, lo.Keys(openapi.Components.Schemas))
+	assert.Equal(t, "#/components/schemas/Customer/properties/Id", openapi.Components.Schemas["Order"].Properties["CustomerId"].AllOf[0].Ref)
+	assert.Equal(t, &JSONSchema{Type: "integer", Format: "int32", Nullable: true, Minimum: lo.ToPtr(0)}, openapi.Components.Schemas["Customer"].Properties["Age"])
+	referrer := openapi.Components.Schemas["Order"].Properties["ReferrerId"]
+	assert.True(t, referrer.Nullable)
+	assert.Equal(t, "#/components/schemas/Customer/properties/Id", referrer.AllOf[0].Ref)
+}
@@ -0,0 +1,62 @@
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/samber/lo"
+	"go/token"
+	"golang.org/x/tools/go/packages"
+	"slices"
+	"strings"
+)
+
+// Problem modeling problem of an entity, position is the declaration of the entity or the field, and it's
+// invalid when the model is not loaded from source code
+type Problem struct {
+	Position token.Position
+	Entity   string
+	Message  string
+}
+
+func (p Problem) Error() string {
+	return lo.If(p.Position.IsValid(), fmt.Sprintf("%s: %s", p.Position, p.Message)).Else(p.Message)
+}
+
+// MarshalJSON marshal the problem with file, line and column of the position
+func (p Problem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		File    string `json:"file,omitempty"`
+		Line    int    `json:"line,omitempty"`
+		Column  int    `json:"column,omitempty"`
+		Entity  string `json:"entity,omitempty"`
+		Message string `json:"message"`
+	}{p.Position.Filename, p.Position.Line, p.Position.Column, p.Entity, p.Message})
+}
+
+// Problems all the modeling problems of the entities in position order
+type Problems []Problem
+
+func (ps Problems) Error() string {
+	return strings.Join(lo.Map(ps, func(p Problem, _ int) string {
+		return p.Error()
+	}), "\n")
+}
+
+// sort sort the problems by position, problems without position keep their order
+func (ps Problems) sort() Problems {
+	slices.SortStableFunc(ps, func(a, b Problem) int {
+		if a.Position.Filename != b.Position.Filename {
+			return strings.Compare(a.Position.Filename, b.Position.Filename)
+		}
+		return lo.Ternary(a.Position.Line != b.Position.Line, a.Position.Line-b.Position.Line, a.Position.Column-b.Position.Column)
+	})
+	return ps
+}
+
+// position return position of the pos in the package, it's invalid when the package is absent
+func position(pkg *packages.Package, pos token.Pos) token.Position {
+	if pkg == nil || pkg.Fset == nil {
+		return token.Position{}
+	}
+	return pkg.Fset.Position(pos)
+}
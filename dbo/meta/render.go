@@ -0,0 +1,65 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"github.com/samber/lo"
+	"github.com/samber/mo"
+	"os"
+	"oss.terrastruct.com/d2/d2graph"
+	"oss.terrastruct.com/d2/d2layouts/d2elklayout"
+	"oss.terrastruct.com/d2/d2lib"
+	"oss.terrastruct.com/d2/d2renderers/d2svg"
+	"oss.terrastruct.com/d2/lib/log"
+	"oss.terrastruct.com/d2/lib/textmeasure"
+	"path/filepath"
+	"strings"
+)
+
+// RenderFormats return the supported image formats of ER diagram. png is not supported, as d2 depends on
+// a headless browser to rasterize the diagram
+func RenderFormats() []string {
+	return []string{"svg"}
+}
+
+// svg lay out the d2 ER diagram with ELK and render it as svg
+func (dbo DBO) svg() mo.Result[[]byte] {
+	diagram := dbo.diagram("d2")
+	if diagram.IsError() {
+		return mo.Err[[]byte](diagram.Error())
+	}
+	ruler, err := textmeasure.NewRuler()
+	if err != nil {
+		return mo.Err[[]byte](err)
+	}
+	renderOpts := &d2svg.RenderOpts{Pad: lo.ToPtr(int64(d2svg.DEFAULT_PADDING))}
+	compileOpts := &d2lib.CompileOptions{
+		Ruler: ruler,
+		LayoutResolver: func(engine string) (d2graph.LayoutGraph, error) {
+			return d2elklayout.DefaultLayout, nil
+		},
+	}
+	ctx := log.WithDefault(context.Background())
+	compiled, _, err := d2lib.Compile(ctx, string(diagram.MustGet().A), compileOpts, renderOpts)
+	if err != nil {
+		return mo.Err[[]byte](err)
+	}
+	return mo.TupleToResult(d2svg.Render(compiled, renderOpts))
+}
+
+// Render render ER diagram as image of the format in process, the diagram is laid out with ELK engine
+func (dbo DBO) Render(path string, format string) error {
+	if !lo.Contains(RenderFormats(), format) {
+		return fmt.Errorf("unsupported render format %s, supported formats are %s", format, strings.Join(RenderFormats(), ", "))
+	}
+	return dbo.each(path, func(_ string, dbo DBO, path string) error {
+		image := dbo.svg()
+		if image.IsError() {
+			return image.Error()
+		}
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(path, fmt.Sprintf("er.%s", format)), image.MustGet(), 0644)
+	})
+}
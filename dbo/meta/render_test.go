@@ -0,0 +1,27 @@
+package meta
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDBO_Render(t *testing.T) {
+	dbo := newDBO(
+		Table{entity: "Customer", name: "customer", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+		}},
+		Table{entity: "Order", name: "orders", columns: []Column{
+			{A: "Id", B: "int64", C: "col=id;pk"},
+			{A: "CustomerId", B: "int64", C: "col=customer_id;ref=Customer.Id"},
+		}},
+	).MustGet()
+	dir := t.TempDir()
+	assert.ErrorContains(t, dbo.Render(dir, "png"), "unsupported render format png")
+	assert.NoError(t, dbo.Render(dir, "svg"))
+	svg, err := os.ReadFile(filepath.Join(dir, "er.svg"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(svg), "<svg")
+	assert.Contains(t, string(svg), "orders")
+}